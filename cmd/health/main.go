@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -13,6 +15,8 @@ import (
 	"github.com/jsteenb2/health/internal/health"
 	"github.com/jsteenb2/health/internal/httpmw"
 	"github.com/jsteenb2/health/internal/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
@@ -22,34 +26,134 @@ func main() {
 		sslCert    = flag.String("sslcert", "", "ssl certification path")
 		sslKey     = flag.String("sslkey", "", "ssl key path")
 
-		filePath      = flag.String("repopath", "endpoints.gob", "file path to the persist the endpoints to disk")
-		nukeEndpoints = flag.Bool("nuke", false, "nuke the existing endpoint checks")
+		repoKind        = flag.String("repo", "file", "repository the endpoints are persisted to, one of file, bolt, sqlite or memory, the sqlite repo persists the probe results alongside the endpoints and requires the secrets of the endpoints to be referenced by secret_ref, the memory repo keeps the probe results and maintenance windows in memory too, unless -historypath or -maintenancepath are set")
+		filePath        = flag.String("repopath", "", "file path to the persist the endpoints to disk, endpoints.gob for the file repo, with the mutations since they were persisted logged to <repopath>.wal, endpoints.db for the bolt repo and endpoints.sqlite for the sqlite repo")
+		repoBackups     = flag.Int("repobackups", 3, "number of replaced snapshots of the endpoints retained as backups at <repopath>.1 through <repopath>.N")
+		repoBackupEvery = flag.Duration("repobackupevery", time.Hour, "min duration between the snapshots of the endpoints retained as backups")
+		nukeEndpoints   = flag.Bool("nuke", false, "nuke the existing endpoint checks")
+		historyPath     = flag.String("historypath", "history", "directory to persist the probe results to disk, results are kept in memory when empty")
+		historySize     = flag.Int("history", 10000, "number of probe results retained per endpoint check when kept in memory")
+		retention       = flag.Duration("retention", 30*24*time.Hour, "duration probe results persisted to disk are retained for")
+		maintenancePath = flag.String("maintenancepath", "maintenance.json", "file path to persist the maintenance windows to disk, windows are kept in memory when empty")
+		secretsDir      = flag.String("secretsdir", "", "directory of the files the secret_ref of a check can reference as file:<name>, environment variables prefixed with "+health.SecretEnvPrefix+" can always be referenced as env:<name>")
+
+		schedulerTick = flag.Duration("tick", time.Second, "resolution at which the probe schedule of the endpoint checks is evaluated")
+		probeWorkers  = flag.Int("workers", 32, "max number of probes in flight")
+		probePerHost  = flag.Int("perhost", 4, "max number of probes in flight against a single host")
+		probeQueue    = flag.Int("queue", 10000, "max number of probes waiting for a worker")
+		modulesPath   = flag.String("modules", "", "JSON file of the named check configs available to /probe, keyed by module name")
 	)
 	flag.Parse()
 
+	switch {
+	case *schedulerTick <= 0:
+		log.Fatal("-tick must be positive")
+	case *probeWorkers <= 0:
+		log.Fatal("-workers must be positive")
+	case *probePerHost <= 0:
+		log.Fatal("-perhost must be positive")
+	case *probeQueue <= 0:
+		log.Fatal("-queue must be positive, every probe would be dropped otherwise")
+	}
+
+	if *filePath == "" {
+		*filePath = defaultRepoPaths[*repoKind]
+	}
+	if *repoKind == "memory" {
+		// nothing is persisted to disk, unless asked to
+		set := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if !set["historypath"] {
+			*historyPath = ""
+		}
+		if !set["maintenancepath"] {
+			*maintenancePath = ""
+		}
+	}
+
 	if *nukeEndpoints {
-		if err := os.Remove(*filePath); err != nil && !os.IsNotExist(err) {
-			log.Fatal(err)
+		if *filePath != "" {
+			// along with the write-ahead logs of the file and sqlite repos
+			for _, path := range []string{*filePath, *filePath + ".wal", *filePath + "-wal", *filePath + "-shm"} {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					log.Fatal(err)
+				}
+			}
+		}
+		if *historyPath != "" {
+			if err := os.RemoveAll(*historyPath); err != nil {
+				log.Fatal(err)
+			}
 		}
 	}
 
-	healthFileRepo, err := health.NewFileRepository(*filePath)
+	healthRepo, closeRepo, err := newRepository(*repoKind, *filePath, *repoBackups, *repoBackupEvery, *retention)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	healthSVC := health.NewSVC(healthFileRepo)
+	historyRepo, ok := healthRepo.(health.HistoryRepository)
+	if !ok {
+		historyRepo = health.NewMemoryHistoryRepository(*historySize)
+		if *historyPath != "" {
+			historyRepo, err = health.NewFileHistoryRepository(*historyPath, *retention)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	maintenanceRepo := health.NewMemoryMaintenanceRepository()
+	if *maintenancePath != "" {
+		maintenanceRepo, err = health.NewFileMaintenanceRepository(*maintenancePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	metricsReg := prometheus.NewRegistry()
+
+	secrets := health.NewSecrets(*secretsDir)
+	prober := health.NewProber(nil, secrets)
+
+	healthSVC := health.NewSVC(
+		healthRepo,
+		health.WithProber(prober),
+		health.WithHistory(historyRepo),
+		health.WithMaintenance(maintenanceRepo),
+		health.WithMetrics(metricsReg),
+		health.WithNotifier(health.NewLogNotifier(nil)),
+		health.WithSecrets(secrets),
+	)
+
+	probeModules := health.DefaultModules()
+	if *modulesPath != "" {
+		if err := loadModules(*modulesPath, probeModules, secrets); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	probePool := health.NewPool(*probeWorkers, *probePerHost, *probeQueue)
+	metricsReg.MustRegister(probePool)
+	scheduler := health.NewScheduler(healthRepo, healthSVC, probePool, *schedulerTick)
+	scheduler.Start()
 
 	var api http.Handler
 	{
-		// prefix the health handler with /api and use the behavior of http.StripPrefix
-		// to provide a 404 if the route does not have a prefix of /api
+		// prefix the health handler with /api, the mux provides a 404 for any
+		// route that does not have a prefix of /api
 		api = http.StripPrefix("/api", health.NewHTTPServer(healthSVC))
 		api = httpmw.Recover()(api)
 		api = httpmw.ContentType("application/json")(api)
 	}
 
-	svr := server.New(*bindAddr, api)
+	mux := http.NewServeMux()
+	mux.Handle("/api/", api)
+	mux.Handle("/debug/pool", httpmw.ContentType("application/json")(poolStats(probePool)))
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsReg, promhttp.HandlerOpts{}))
+	mux.Handle("/probe", health.NewProbeHandler(prober, probeModules))
+
+	svr := server.New(*bindAddr, httpmw.Metrics(metricsReg)(mux))
 	log.Println("listening at: ", *bindAddr)
 	go func(sslEnabled bool, cert, key string) {
 		if err := svr.Listen(sslEnabled, cert, key); err != nil {
@@ -63,6 +167,66 @@ func main() {
 		log.Fatal(err)
 	}
 	log.Println("server stopped")
+
+	scheduler.Stop()
+	log.Println("scheduler stopped")
+
+	if err := closeRepo(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+var defaultRepoPaths = map[string]string{
+	"file":   "endpoints.gob",
+	"bolt":   "endpoints.db",
+	"sqlite": "endpoints.sqlite",
+}
+
+func newRepository(kind, path string, backups int, backupEvery, retention time.Duration) (health.Repository, func() error, error) {
+	noClose := func() error { return nil }
+
+	switch kind {
+	case "file":
+		repo, err := health.NewFileRepository(path, health.WithBackups(backups), health.WithBackupEvery(backupEvery))
+		return repo, noClose, err
+	case "bolt":
+		repo, err := health.NewBoltRepository(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, repo.Close, nil
+	case "memory":
+		return health.NewMemoryRepository(), noClose, nil
+	case "sqlite":
+		repo, err := health.NewSQLiteRepository(path, retention)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, repo.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported repo %q, must be one of file, bolt, sqlite or memory", kind)
+	}
+}
+
+func loadModules(path string, modules map[string]health.Check, secrets *health.Secrets) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&modules); err != nil {
+		return err
+	}
+	return health.ValidateModules(modules, secrets)
+}
+
+func poolStats(p *health.Pool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewEncoder(w).Encode(p.Stats()); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
 }
 
 func systemCtx() context.Context {
@@ -0,0 +1,206 @@
+package health
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// checksBucket holds the checks keyed by id, orderBucket holds the ids
+	// of the checks keyed by the sequence they were created in.
+	checksBucket = []byte("checks")
+	orderBucket  = []byte("order")
+	metaBucket   = []byte("meta")
+
+	countKey = []byte("count")
+)
+
+// BoltRepository persists the checks to a bbolt database, every mutation a
+// transaction of its own.
+type BoltRepository struct {
+	db *bolt.DB
+}
+
+var _ Repository = (*BoltRepository)(nil)
+
+// boltCheck is the check as stored, with the key of the check in the order
+// bucket.
+type boltCheck struct {
+	Seq   uint64 `json:"seq"`
+	Check Check  `json:"check"`
+}
+
+func NewBoltRepository(path string) (*BoltRepository, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{checksBucket, orderBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltRepository{db: db}, nil
+}
+
+func (r *BoltRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *BoltRepository) Create(check Check) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		checks, order := tx.Bucket(checksBucket), tx.Bucket(orderBucket)
+		if checks.Get([]byte(check.ID)) != nil {
+			return errEndpointExists
+		}
+
+		seq, err := order.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := putBoltCheck(checks, boltCheck{Seq: seq, Check: check}); err != nil {
+			return err
+		}
+		if err := order.Put(seqKey(seq), []byte(check.ID)); err != nil {
+			return err
+		}
+		return addCount(tx, 1)
+	})
+}
+
+func (r *BoltRepository) List(page, size int) (int, []Check) {
+	var (
+		total int
+		out   = []Check{}
+	)
+	// errors are not surfaced by List, the checks read up to an error are
+	// provided
+	r.db.View(func(tx *bolt.Tx) error {
+		total = int(btoi(tx.Bucket(metaBucket).Get(countKey)))
+
+		skip := 0
+		if size != -1 {
+			skip = size * (page - 1)
+			if skip >= total {
+				return nil
+			}
+		}
+
+		checks := tx.Bucket(checksBucket)
+		c := tx.Bucket(orderBucket).Cursor()
+		for k, id := c.First(); k != nil; k, id = c.Next() {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if size != -1 && len(out) == size {
+				break
+			}
+
+			bc, err := getBoltCheck(checks, id)
+			if err != nil {
+				return err
+			}
+			out = append(out, bc.Check)
+		}
+		return nil
+	})
+	return total, out
+}
+
+func (r *BoltRepository) Read(id string) (Check, error) {
+	var bc boltCheck
+	err := r.db.View(func(tx *bolt.Tx) error {
+		var err error
+		bc, err = getBoltCheck(tx.Bucket(checksBucket), []byte(id))
+		return err
+	})
+	return bc.Check, err
+}
+
+func (r *BoltRepository) Update(check Check) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		checks := tx.Bucket(checksBucket)
+		bc, err := getBoltCheck(checks, []byte(check.ID))
+		if err != nil {
+			return err
+		}
+
+		bc.Check = check
+		return putBoltCheck(checks, bc)
+	})
+}
+
+func (r *BoltRepository) Delete(id string) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		checks := tx.Bucket(checksBucket)
+		bc, err := getBoltCheck(checks, []byte(id))
+		if err == errCheckNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := checks.Delete([]byte(id)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(orderBucket).Delete(seqKey(bc.Seq)); err != nil {
+			return err
+		}
+		return addCount(tx, -1)
+	})
+}
+
+func getBoltCheck(b *bolt.Bucket, id []byte) (boltCheck, error) {
+	v := b.Get(id)
+	if v == nil {
+		return boltCheck{}, errCheckNotFound
+	}
+
+	var bc boltCheck
+	if err := json.Unmarshal(v, &bc); err != nil {
+		return boltCheck{}, err
+	}
+	return bc, nil
+}
+
+func putBoltCheck(b *bolt.Bucket, bc boltCheck) error {
+	v, err := json.Marshal(bc)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(bc.Check.ID), v)
+}
+
+func addCount(tx *bolt.Tx, delta int64) error {
+	meta := tx.Bucket(metaBucket)
+	count := int64(btoi(meta.Get(countKey))) + delta
+	return meta.Put(countKey, seqKey(uint64(count)))
+}
+
+// seqKey encodes the sequence big endian, such that the keys sort in the
+// order of the sequence.
+func seqKey(seq uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, seq)
+	return b
+}
+
+func btoi(b []byte) uint64 {
+	if len(b) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
@@ -0,0 +1,167 @@
+package health_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestBoltRepository(t *testing.T) {
+	newRepo := func(t *testing.T) (*health.BoltRepository, string, func()) {
+		t.Helper()
+
+		tmpDir, err := ioutil.TempDir("", "")
+		mustNoError(t, err)
+
+		path := filepath.Join(tmpDir, "endpoints.db")
+		repo, err := health.NewBoltRepository(path)
+		mustNoError(t, err)
+
+		return repo, path, func() {
+			repo.Close()
+			os.RemoveAll(tmpDir)
+		}
+	}
+
+	newChecks := func(n int) []health.Check {
+		checks := make([]health.Check, 0, n)
+		for i := 0; i < n; i++ {
+			checks = append(checks, health.Check{ID: "id-" + strconv.Itoa(i), Endpoint: "http://example.com/" + strconv.Itoa(i)})
+		}
+		return checks
+	}
+
+	t.Run("create", func(t *testing.T) {
+		repo, _, done := newRepo(t)
+		defer done()
+
+		check := health.Check{ID: "id", Endpoint: "http://example.com", Labels: map[string]string{"team": "db"}}
+		mustNoError(t, repo.Create(check))
+
+		got, err := repo.Read(check.ID)
+		mustNoError(t, err)
+		equal(t, check, got, "unexpected check")
+
+		t.Run("fails when check already exists", func(t *testing.T) {
+			mustError(t, repo.Create(check))
+		})
+	})
+
+	t.Run("read missing check", func(t *testing.T) {
+		repo, _, done := newRepo(t)
+		defer done()
+
+		_, err := repo.Read("id")
+		mustError(t, err)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		repo, _, done := newRepo(t)
+		defer done()
+
+		check := health.Check{ID: "id", Endpoint: "http://example.com"}
+		mustNoError(t, repo.Create(check))
+
+		check.State = health.StateUp
+		check.Code = 200
+		mustNoError(t, repo.Update(check))
+
+		got, err := repo.Read(check.ID)
+		mustNoError(t, err)
+		equal(t, check, got, "check not updated")
+
+		t.Run("fails when check does not exist", func(t *testing.T) {
+			mustError(t, repo.Update(health.Check{ID: "missing"}))
+		})
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo, _, done := newRepo(t)
+		defer done()
+
+		checks := newChecks(3)
+		for _, c := range checks {
+			mustNoError(t, repo.Create(c))
+		}
+
+		mustNoError(t, repo.Delete(checks[1].ID))
+		mustNoError(t, repo.Delete("missing"))
+
+		_, err := repo.Read(checks[1].ID)
+		mustError(t, err)
+
+		total, got := repo.List(0, -1)
+		equal(t, 2, total, "unexpected total")
+		equal(t, []health.Check{checks[0], checks[2]}, got, "unexpected checks")
+	})
+
+	t.Run("list", func(t *testing.T) {
+		repo, _, done := newRepo(t)
+		defer done()
+
+		checks := newChecks(20)
+		for _, c := range checks {
+			mustNoError(t, repo.Create(c))
+		}
+
+		tests := []struct {
+			name       string
+			page, size int
+			expected   []health.Check
+		}{
+			{name: "first page", page: 1, size: 5, expected: checks[:5]},
+			{name: "middle page", page: 3, size: 5, expected: checks[10:15]},
+			{name: "partial page", page: 2, size: 19, expected: checks[19:]},
+			{name: "page that does not exist", page: 100, size: 10, expected: []health.Check{}},
+			{name: "all checks", size: -1, expected: checks},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				total, got := repo.List(tt.page, tt.size)
+
+				equal(t, len(checks), total, "unexpected total")
+				equal(t, tt.expected, got, "unexpected checks")
+			}
+
+			t.Run(tt.name, fn)
+		}
+
+		t.Run("pages keep the order checks were created in", func(t *testing.T) {
+			mustNoError(t, repo.Delete(checks[2].ID))
+			mustNoError(t, repo.Update(checks[0]))
+			newCheck := health.Check{ID: "new-id", Endpoint: "http://example.com/new"}
+			mustNoError(t, repo.Create(newCheck))
+
+			_, got := repo.List(1, 3)
+			equal(t, []health.Check{checks[0], checks[1], checks[3]}, got, "unexpected first page")
+
+			total, got := repo.List(7, 3)
+			equal(t, len(checks), total, "unexpected total")
+			equal(t, []health.Check{checks[19], newCheck}, got, "unexpected last page")
+		})
+	})
+
+	t.Run("checks are persisted", func(t *testing.T) {
+		repo, path, done := newRepo(t)
+		defer done()
+
+		checks := newChecks(3)
+		for _, c := range checks {
+			mustNoError(t, repo.Create(c))
+		}
+		mustNoError(t, repo.Close())
+
+		repo, err := health.NewBoltRepository(path)
+		mustNoError(t, err)
+		defer repo.Close()
+
+		total, got := repo.List(0, -1)
+		equal(t, 3, total, "unexpected total")
+		equal(t, checks, got, "unexpected checks")
+	})
+}
@@ -0,0 +1,198 @@
+package health
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidCron = errors.New("schedule must be a cron expression of the form: minute hour day-of-month month day-of-week")
+
+// cronSchedule is a parsed cron expression, each field holding a bit for
+// every value it matches.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// anyDOM and anyDOW are set when the day fields are unrestricted, as
+	// a day matches either of the restricted day fields.
+	anyDOM, anyDOW bool
+}
+
+var cronFields = []struct {
+	min, max int
+}{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week, 0 and 7 are both Sunday
+}
+
+func parseCron(expr string) (cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return cronSchedule{}, errInvalidCron
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		b, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return cronSchedule{}, err
+		}
+		bits[i] = b
+	}
+
+	// Sunday is matched as 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return cronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		anyDOM: fields[2] == "*",
+		anyDOW: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps,
+// i.e. "*", "5", "1-5", "*/15" or "0-30/10".
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, errInvalidCron
+			}
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, errInvalidCron
+			}
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, errInvalidCron
+			}
+			lo, hi = v, v
+			if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, errInvalidCron
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// matchesDay reports whether the schedule fires on the day of t.
+func (s cronSchedule) matchesDay(t time.Time) bool {
+	if s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.anyDOM && s.anyDOW:
+		return true
+	case s.anyDOM:
+		return dow
+	case s.anyDOW:
+		return dom
+	}
+	return dom || dow
+}
+
+// lastFire provides the latest time, no earlier than t less the lookback and
+// no later than t, the schedule fired at, in the location of t. Rather than
+// testing every minute, the days are walked back from t, the first day the
+// schedule fires on providing the latest hour and minute it fires at.
+func (s cronSchedule) lastFire(t time.Time, lookback time.Duration) (time.Time, bool) {
+	earliest := t.Add(-lookback)
+	y, m, d := t.Date()
+	loc := t.Location()
+
+	for day := 0; ; day++ {
+		date := time.Date(y, m, d-day, 0, 0, 0, 0, loc)
+		if date.AddDate(0, 0, 1).Before(earliest) {
+			return time.Time{}, false
+		}
+		if !s.matchesDay(date) {
+			continue
+		}
+
+		maxHour := 23
+		if day == 0 {
+			maxHour = t.Hour()
+		}
+		for h, ok := highestBit(s.hour, maxHour); ok; h, ok = highestBit(s.hour, h-1) {
+			maxMinute := 59
+			if day == 0 && h == t.Hour() {
+				maxMinute = t.Minute()
+			}
+			minute, ok := highestBit(s.minute, maxMinute)
+			if !ok {
+				continue
+			}
+
+			onClock := func(c time.Time) bool {
+				return c.Hour() == h && c.Minute() == minute
+			}
+
+			fired := time.Date(y, m, d-day, h, minute, 0, 0, loc)
+			if !onClock(fired) {
+				// the wall clock time was skipped by a daylight saving
+				// transition
+				continue
+			}
+			// the wall clock time is repeated when a daylight saving
+			// transition turns the clock back, the latest occurrence
+			// no later than t is the one that fired
+			if later := fired.Add(time.Hour); onClock(later) && !later.After(t) {
+				fired = later
+			} else if fired.After(t) {
+				earlier := fired.Add(-time.Hour)
+				if !onClock(earlier) || earlier.After(t) {
+					continue
+				}
+				fired = earlier
+			}
+			if fired.Before(earliest) {
+				return time.Time{}, false
+			}
+			return fired, true
+		}
+	}
+}
+
+// highestBit provides the highest bit set in the bits, no higher than max.
+func highestBit(bits uint64, max int) (int, bool) {
+	for v := max; v >= 0; v-- {
+		if bits&(1<<uint(v)) != 0 {
+			return v, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,202 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DNSQuery configures the resolution of a dns check. The endpoint of the check
+// is the name resolved.
+type DNSQuery struct {
+	// Resolver is the host:port of the DNS server queried, the port defaults
+	// to 53. The system resolver is used when it is not set.
+	Resolver string `json:"resolver,omitempty"`
+	// RecordType is one of A, AAAA, CNAME, MX, NS or TXT, defaulting to A.
+	RecordType string `json:"record_type,omitempty"`
+	// Records must all be present in the answer, i.e. "10.0.0.1" for an A
+	// record or "mail.example.com" for an MX record.
+	Records []string `json:"records,omitempty"`
+}
+
+const AssertionRecords = "records"
+
+var (
+	errInvalidDNSName    = errors.New("dns endpoint must be a valid domain name")
+	errInvalidRecordType = errors.New("record_type must be one of A, AAAA, CNAME, MX, NS or TXT")
+	errInvalidResolver   = errors.New("resolver must be of the form host:port")
+)
+
+func validateDNSName(name string) error {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return errInvalidDNSName
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return errInvalidDNSName
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return errInvalidDNSName
+			}
+		}
+	}
+	return nil
+}
+
+func validateDNSQuery(q *DNSQuery) error {
+	if q == nil {
+		return nil
+	}
+
+	switch q.recordType() {
+	case "A", "AAAA", "CNAME", "MX", "NS", "TXT":
+	default:
+		return errInvalidRecordType
+	}
+
+	if q.Resolver != "" {
+		if err := validateHostPort(q.resolver()); err != nil {
+			return errInvalidResolver
+		}
+	}
+	return nil
+}
+
+func (q *DNSQuery) recordType() string {
+	if q == nil || q.RecordType == "" {
+		return "A"
+	}
+	return strings.ToUpper(q.RecordType)
+}
+
+// resolver provides the address of the resolver with the port defaulted.
+func (q *DNSQuery) resolver() string {
+	if q == nil || q.Resolver == "" {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(q.Resolver); err != nil {
+		return net.JoinHostPort(strings.Trim(q.Resolver, "[]"), "53")
+	}
+	return q.Resolver
+}
+
+type dnsProber struct{}
+
+var _ Prober = (*dnsProber)(nil)
+
+// NewDNSProber provides a prober that resolves the endpoint of the check
+// against the resolver of its query, asserting the expected records are
+// present in the answer.
+func NewDNSProber() Prober {
+	return new(dnsProber)
+}
+
+func (p *dnsProber) Probe(ctx context.Context, c Check) Result {
+	res := Result{
+		CheckID: c.ID,
+		Checked: time.Now(),
+	}
+
+	resolver := net.DefaultResolver
+	if addr := c.DNS.resolver(); addr != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		}
+	}
+
+	records, err := lookup(ctx, resolver, c.DNS.recordType(), c.Endpoint)
+	res.Duration = Duration(time.Since(res.Checked))
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	if c.DNS != nil {
+		for _, expected := range c.DNS.Records {
+			if !contains(records, normalizeRecord(c.DNS.recordType(), expected)) {
+				res.Assertion = AssertionRecords
+				res.Failure = fmt.Sprintf("record %q not found in answer [%s]", expected, strings.Join(records, ", "))
+				return res
+			}
+		}
+	}
+
+	res.OK = true
+	return res
+}
+
+func lookup(ctx context.Context, r *net.Resolver, recordType, name string) ([]string, error) {
+	var records []string
+	switch recordType {
+	case "A", "AAAA":
+		network := "ip4"
+		if recordType == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := r.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			records = append(records, ip.String())
+		}
+	case "CNAME":
+		cname, err := r.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, cname)
+	case "MX":
+		mxs, err := r.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			records = append(records, mx.Host)
+		}
+	case "NS":
+		nss, err := r.LookupNS(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			records = append(records, ns.Host)
+		}
+	case "TXT":
+		txts, err := r.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, txts...)
+	default:
+		return nil, errInvalidRecordType
+	}
+
+	for i, rec := range records {
+		records[i] = normalizeRecord(recordType, rec)
+	}
+	return records, nil
+}
+
+// normalizeRecord provides the record in the form it is asserted on, names
+// are compared without their trailing dot and regardless of case.
+func normalizeRecord(recordType, rec string) string {
+	switch recordType {
+	case "A", "AAAA":
+		if ip := net.ParseIP(rec); ip != nil {
+			return ip.String()
+		}
+	case "CNAME", "MX", "NS":
+		return strings.ToLower(strings.TrimSuffix(rec, "."))
+	}
+	return rec
+}
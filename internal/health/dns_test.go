@@ -0,0 +1,137 @@
+package health_test
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestDNSProber(t *testing.T) {
+	const (
+		typeA   = 1
+		typeTXT = 16
+	)
+
+	stub := newDNSStub(t, map[uint16][][]byte{
+		typeA:   {{10, 0, 0, 1}, {10, 0, 0, 2}},
+		typeTXT: {append([]byte{byte(len("v=spf1 -all"))}, "v=spf1 -all"...)},
+	})
+	defer stub.Close()
+
+	tests := []struct {
+		name              string
+		query             *health.DNSQuery
+		expectedOK        bool
+		expectedAssertion string
+	}{
+		{
+			name:       "a records resolve",
+			query:      &health.DNSQuery{},
+			expectedOK: true,
+		},
+		{
+			name:       "expected a records are present",
+			query:      &health.DNSQuery{Records: []string{"10.0.0.2", "10.0.0.1"}},
+			expectedOK: true,
+		},
+		{
+			name:              "expected a record is missing",
+			query:             &health.DNSQuery{Records: []string{"10.0.0.3"}},
+			expectedAssertion: health.AssertionRecords,
+		},
+		{
+			name:       "expected txt record is present",
+			query:      &health.DNSQuery{RecordType: "TXT", Records: []string{"v=spf1 -all"}},
+			expectedOK: true,
+		},
+		{
+			name:  "no records fails the probe",
+			query: &health.DNSQuery{RecordType: "AAAA"},
+		},
+	}
+
+	for _, tt := range tests {
+		fn := func(t *testing.T) {
+			query := *tt.query
+			query.Resolver = stub.LocalAddr().String()
+
+			res := health.NewDNSProber().Probe(context.Background(), health.Check{
+				ID:       "id",
+				Type:     health.TypeDNS,
+				Endpoint: "svc.example.com",
+				DNS:      &query,
+			})
+
+			equal(t, "id", res.CheckID, "unexpected check id")
+			equal(t, tt.expectedOK, res.OK, "unexpected ok: "+res.Error+res.Failure)
+			equal(t, tt.expectedAssertion, res.Assertion, "unexpected failed assertion")
+			if !tt.expectedOK && tt.expectedAssertion == "" && res.Error == "" {
+				t.Error("expected an error to be recorded")
+			}
+		}
+
+		t.Run(tt.name, fn)
+	}
+}
+
+// newDNSStub starts a DNS server answering every question with the records of
+// its type. The rdata of the records is provided in its wire format.
+func newDNSStub(t *testing.T, records map[uint16][][]byte) net.PacketConn {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	mustNoError(t, err)
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := dnsAnswer(buf[:n], records); resp != nil {
+				conn.WriteTo(resp, addr)
+			}
+		}
+	}()
+	return conn
+}
+
+func dnsAnswer(query []byte, records map[uint16][][]byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	// skip the labels of the question name to find its type and class
+	end := 12
+	for end < len(query) && query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end += 5
+	if end > len(query) {
+		return nil
+	}
+	question := query[12:end]
+	qtype := binary.BigEndian.Uint16(question[len(question)-4:])
+	answers := records[qtype]
+
+	resp := make([]byte, 12, 512)
+	copy(resp, query[:2])
+	binary.BigEndian.PutUint16(resp[2:], 0x8180) // response, recursion desired and available
+	binary.BigEndian.PutUint16(resp[4:], 1)
+	binary.BigEndian.PutUint16(resp[6:], uint16(len(answers)))
+	resp = append(resp, question...)
+	for _, rdata := range answers {
+		rr := make([]byte, 12)
+		binary.BigEndian.PutUint16(rr[0:], 0xc00c) // pointer to the question name
+		binary.BigEndian.PutUint16(rr[2:], qtype)
+		binary.BigEndian.PutUint16(rr[4:], 1) // class IN
+		binary.BigEndian.PutUint32(rr[6:], 60)
+		binary.BigEndian.PutUint16(rr[10:], uint16(len(rdata)))
+		resp = append(append(resp, rr...), rdata...)
+	}
+	return resp
+}
@@ -0,0 +1,35 @@
+package health
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration that is represented as a human readable
+// string, i.e. "5s" or "10m", when encoded as JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	dur, err := parseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(dur)
+	return nil
+}
+
+// parseDuration extends time.ParseDuration with support for whole days, i.e. "7d".
+func parseDuration(s string) (time.Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err == nil {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
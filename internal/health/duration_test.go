@@ -0,0 +1,46 @@
+package health_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestDuration(t *testing.T) {
+	t.Run("marshals to a human readable string", func(t *testing.T) {
+		b, err := json.Marshal(health.Duration(90 * time.Second))
+		mustNoError(t, err)
+
+		equal(t, `"1m30s"`, string(b), "unexpected json")
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		tests := []struct {
+			input    string
+			expected time.Duration
+		}{
+			{input: `"5s"`, expected: 5 * time.Second},
+			{input: `"10m"`, expected: 10 * time.Minute},
+			{input: `"1h30m"`, expected: 90 * time.Minute},
+			{input: `"7d"`, expected: 7 * 24 * time.Hour},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				var d health.Duration
+				mustNoError(t, json.Unmarshal([]byte(tt.input), &d))
+
+				equal(t, health.Duration(tt.expected), d, "unexpected duration")
+			}
+
+			t.Run(tt.input, fn)
+		}
+
+		t.Run("invalid duration", func(t *testing.T) {
+			var d health.Duration
+			mustError(t, json.Unmarshal([]byte(`"five seconds"`), &d))
+		})
+	})
+}
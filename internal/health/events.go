@@ -0,0 +1,41 @@
+package health
+
+import (
+	"fmt"
+	"time"
+)
+
+// Event records the transition of a check from one state to another.
+type Event struct {
+	CheckID string    `json:"check_id"`
+	Time    time.Time `json:"time"`
+	From    State     `json:"from"`
+	To      State     `json:"to"`
+	Reason  string    `json:"reason"`
+}
+
+const defaultEventsWindow = 30 * 24 * time.Hour
+
+// transitionReason describes why the check transitioned from the previous
+// state into its state.
+func transitionReason(prev State, c Check) string {
+	switch {
+	case c.State == StateFlapping:
+		return fmt.Sprintf("state changed across %.0f%% of the latest %d probes", c.Flap.Change, c.Flap.Samples)
+	case prev == StateFlapping:
+		return fmt.Sprintf("stopped flapping, state changed across %.0f%% of the latest %d probes", c.Flap.Change, c.Flap.Samples)
+	}
+
+	switch c.State {
+	case StateUp:
+		if c.ConsecutiveSuccesses > 1 {
+			return fmt.Sprintf("%d consecutive probes succeeded", c.ConsecutiveSuccesses)
+		}
+		return "probe succeeded"
+	case StateDown, StateUnknown:
+		if c.ConsecutiveFailures > 1 {
+			return fmt.Sprintf("%d consecutive probes failed: %s", c.ConsecutiveFailures, c.Failure)
+		}
+	}
+	return c.Failure
+}
@@ -0,0 +1,250 @@
+package health
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expectation declares what a healthy response looks like. A check without an
+// expectation is healthy when it responds with a 2xx or 3xx status code.
+type Expectation struct {
+	// Codes is a comma separated list of status codes and status code
+	// ranges, i.e. "200-299,304".
+	Codes string `json:"codes,omitempty"`
+	// Headers must be present in the response. A header with an empty value
+	// only needs to be present, otherwise the value must match exactly.
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      string            `json:"body,omitempty"`
+	BodyRegex string            `json:"body_regex,omitempty"`
+	// JSONPath selects a value from a JSON response body, i.e. "$.status" or
+	// "$.checks[0].state", which must be equal to JSONValue when it is set.
+	JSONPath  string `json:"json_path,omitempty"`
+	JSONValue string `json:"json_value,omitempty"`
+}
+
+const (
+	AssertionCodes     = "codes"
+	AssertionHeaders   = "headers"
+	AssertionBody      = "body"
+	AssertionBodyRegex = "body_regex"
+	AssertionJSONPath  = "json_path"
+)
+
+var (
+	errInvalidCodes     = errors.New("expected codes must be a comma separated list of status codes or ranges, i.e. 200-299,304")
+	errInvalidBodyRegex = errors.New("expected body regex must be a valid regular expression")
+	errInvalidJSONPath  = errors.New("expected json path must be of the form $.field[0].field")
+)
+
+var defaultCodes = []codeRange{{min: 200, max: 399}}
+
+func validateExpectation(e *Expectation) error {
+	if e == nil {
+		return nil
+	}
+	if _, err := parseCodes(e.Codes); err != nil {
+		return err
+	}
+	if _, err := regexp.Compile(e.BodyRegex); err != nil {
+		return errInvalidBodyRegex
+	}
+	if e.JSONPath == "" && e.JSONValue != "" {
+		return errInvalidJSONPath
+	}
+	if _, err := parseJSONPath(e.JSONPath); err != nil {
+		return err
+	}
+	return nil
+}
+
+// evaluate asserts the response against the expectation. The failed assertion
+// is returned along with a description of the failure.
+func (e *Expectation) evaluate(code int, header http.Header, body []byte) (assertion, failure string) {
+	if e == nil {
+		e = new(Expectation)
+	}
+
+	codes, err := parseCodes(e.Codes)
+	if err != nil {
+		return AssertionCodes, err.Error()
+	}
+	if !matchCodes(codes, code) {
+		return AssertionCodes, fmt.Sprintf("status code %d is not one of the expected codes", code)
+	}
+
+	for name, v := range e.Headers {
+		values, ok := header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return AssertionHeaders, fmt.Sprintf("header %s is missing", name)
+		}
+		if v != "" && !contains(values, v) {
+			return AssertionHeaders, fmt.Sprintf("header %s is %q, expected %q", name, strings.Join(values, ","), v)
+		}
+	}
+
+	if e.Body != "" && !bytes.Contains(body, []byte(e.Body)) {
+		return AssertionBody, fmt.Sprintf("body does not contain %q", e.Body)
+	}
+
+	if e.BodyRegex != "" {
+		re, err := regexp.Compile(e.BodyRegex)
+		if err != nil {
+			return AssertionBodyRegex, errInvalidBodyRegex.Error()
+		}
+		if !re.Match(body) {
+			return AssertionBodyRegex, fmt.Sprintf("body does not match %q", e.BodyRegex)
+		}
+	}
+
+	if e.JSONPath != "" {
+		if failure := assertJSONPath(e.JSONPath, e.JSONValue, body); failure != "" {
+			return AssertionJSONPath, failure
+		}
+	}
+	return "", ""
+}
+
+type codeRange struct {
+	min, max int
+}
+
+func parseCodes(spec string) ([]codeRange, error) {
+	if strings.TrimSpace(spec) == "" {
+		return defaultCodes, nil
+	}
+
+	var codes []codeRange
+	for _, part := range strings.Split(spec, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+
+		min, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, errInvalidCodes
+		}
+		max := min
+		if len(bounds) == 2 {
+			if max, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, errInvalidCodes
+			}
+		}
+		if min < 100 || max > 599 || min > max {
+			return nil, errInvalidCodes
+		}
+		codes = append(codes, codeRange{min: min, max: max})
+	}
+	return codes, nil
+}
+
+func matchCodes(codes []codeRange, code int) bool {
+	for _, c := range codes {
+		if code >= c.min && code <= c.max {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonPathSegment is either the key of an object or the index of an array.
+type jsonPathSegment struct {
+	key   string
+	index int
+}
+
+var jsonPathSegmentRe = regexp.MustCompile(`^(?:\.([^.\[\]]+)|\[(\d+)\])`)
+
+func parseJSONPath(p string) ([]jsonPathSegment, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "$") {
+		return nil, errInvalidJSONPath
+	}
+
+	var segments []jsonPathSegment
+	for rest := p[1:]; rest != ""; {
+		m := jsonPathSegmentRe.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, errInvalidJSONPath
+		}
+		rest = rest[len(m[0]):]
+
+		if m[1] != "" {
+			segments = append(segments, jsonPathSegment{key: m[1], index: -1})
+			continue
+		}
+		idx, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil, errInvalidJSONPath
+		}
+		segments = append(segments, jsonPathSegment{index: idx})
+	}
+	return segments, nil
+}
+
+func assertJSONPath(p, expected string, body []byte) string {
+	segments, err := parseJSONPath(p)
+	if err != nil {
+		return err.Error()
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "body is not valid JSON"
+	}
+
+	for _, seg := range segments {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[seg.key]
+			if seg.index >= 0 || !ok {
+				return fmt.Sprintf("%s not found in body", p)
+			}
+			v = child
+		case []interface{}:
+			if seg.index < 0 || seg.index >= len(node) {
+				return fmt.Sprintf("%s not found in body", p)
+			}
+			v = node[seg.index]
+		default:
+			return fmt.Sprintf("%s not found in body", p)
+		}
+	}
+
+	if expected == "" {
+		return ""
+	}
+	if got := jsonValueString(v); got != expected {
+		return fmt.Sprintf("%s is %q, expected %q", p, got, expected)
+	}
+	return ""
+}
+
+func jsonValueString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case nil:
+		return "null"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
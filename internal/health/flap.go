@@ -0,0 +1,115 @@
+package health
+
+import (
+	"errors"
+	"math"
+)
+
+// Flap tracks how often the probes of a check change between success and
+// failure, in the vein of the Nagios flap detection. A check is flapping once
+// the weighted percent of state changes across its latest probes exceeds its
+// high threshold, and stops once it drops below its low threshold.
+type Flap struct {
+	// History holds whether each of the latest probes succeeded, the latest
+	// in the lowest bit, with Samples of its bits in use.
+	History uint32 `json:"history"`
+	Samples int    `json:"samples"`
+	// Change is the weighted percent of state changes across the history.
+	Change float64 `json:"percent_state_change"`
+	// State is the state the check settles on once it stops flapping.
+	State State `json:"state,omitempty"`
+}
+
+const (
+	// flapWindow is the number of probes considered, providing 20 possible
+	// state changes.
+	flapWindow      = 21
+	defaultFlapLow  = 25
+	defaultFlapHigh = 50
+)
+
+var errInvalidFlapThreshold = errors.New("flap thresholds must be between 0 and 100, with the low threshold below the high threshold")
+
+func validateFlapThresholds(c Check) error {
+	low, high := flapThresholds(c)
+	if c.FlapLowThreshold < 0 || c.FlapHighThreshold < 0 || high > 100 || low >= high {
+		return errInvalidFlapThreshold
+	}
+	return nil
+}
+
+func flapThresholds(c Check) (low, high float64) {
+	low, high = c.FlapLowThreshold, c.FlapHighThreshold
+	if low == 0 {
+		low = defaultFlapLow
+	}
+	if high == 0 {
+		high = defaultFlapHigh
+	}
+	return low, high
+}
+
+func (f *Flap) record(ok bool) {
+	f.History <<= 1
+	if ok {
+		f.History |= 1
+	}
+	f.History &= 1<<flapWindow - 1
+	if f.Samples < flapWindow {
+		f.Samples++
+	}
+	f.Change = f.percentStateChange()
+}
+
+// percentStateChange weighs the state changes of the history from 0.8, for
+// the oldest, to 1.2, for the latest, so recent changes count for more.
+func (f *Flap) percentStateChange() float64 {
+	changes := f.Samples - 1
+	if changes <= 0 {
+		return 0
+	}
+
+	// a bit of diff is set for every probe that changed from the one prior
+	diff := f.History ^ f.History>>1
+
+	var weighted float64
+	for i := 0; i < changes; i++ {
+		if diff>>uint(i)&1 == 0 {
+			continue
+		}
+		// i is the age of the change, 0 being the latest
+		weight := 1.2
+		if changes > 1 {
+			weight = 1.2 - 0.4*float64(i)/float64(changes-1)
+		}
+		weighted += weight
+	}
+	return math.Round(weighted/float64(changes)*100*100) / 100
+}
+
+// detectFlapping records the outcome of the probe, moving the check in and out
+// of the flapping state. The state of the check, as determined by its
+// thresholds, is retained while it flaps.
+func detectFlapping(c *Check, ok, wasFlapping bool) {
+	c.Flap.record(ok)
+
+	low, high := flapThresholds(*c)
+	flapping := c.Flap.Samples == flapWindow && c.Flap.Change > high
+	if wasFlapping {
+		flapping = c.Flap.Change >= low
+	}
+
+	c.Flap.State = ""
+	if flapping {
+		c.Flap.State = c.State
+		c.State = StateFlapping
+	}
+}
+
+// settledState provides the state of the check disregarding flapping.
+func settledState(c Check) State {
+	if c.State == StateFlapping {
+		return c.Flap.State
+	}
+	return c.State
+}
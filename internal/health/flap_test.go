@@ -0,0 +1,118 @@
+package health_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestFlapping(t *testing.T) {
+	id := strings.Repeat("a", 44)
+
+	newSVC := func(check health.Check, results func(i int) bool, opts ...health.SVCOption) health.SVC {
+		check.ID = id
+		check.Endpoint = "http://example.com"
+		repo := &fakeRepo{
+			readFn: func(id string) (health.Check, error) {
+				return check, nil
+			},
+			updateFn: func(c health.Check) error {
+				check = c
+				return nil
+			},
+		}
+
+		var probes int
+		prober := &fakeProber{
+			probeFn: func(ctx context.Context, c health.Check) health.Result {
+				probes++
+				return health.Result{CheckID: c.ID, OK: results(probes), Checked: time.Unix(1500000000, 0).Add(time.Duration(probes) * time.Minute)}
+			},
+		}
+		return health.NewSVC(repo, append([]health.SVCOption{health.WithProber(prober)}, opts...)...)
+	}
+
+	run := func(t *testing.T, svc health.SVC, n int) health.Check {
+		t.Helper()
+
+		var check health.Check
+		for i := 0; i < n; i++ {
+			var err error
+			check, err = svc.Run(context.Background(), id)
+			mustNoError(t, err)
+		}
+		return check
+	}
+
+	alternating := func(i int) bool { return i%2 == 0 }
+
+	t.Run("check changing state on every probe is flapping", func(t *testing.T) {
+		svc := newSVC(health.Check{State: health.StatePending}, alternating)
+
+		check := run(t, svc, 20)
+		equal(t, false, check.State == health.StateFlapping, "flapping before the window is full")
+
+		check = run(t, svc, 1)
+		equal(t, health.StateFlapping, check.State, "unexpected state")
+		equal(t, float64(100), check.Flap.Change, "unexpected percent state change")
+		equal(t, health.StateDown, check.Flap.State, "unexpected settled state")
+	})
+
+	t.Run("check stops flapping once it stabilises", func(t *testing.T) {
+		svc := newSVC(health.Check{State: health.StatePending}, func(i int) bool {
+			return i > 21 || i%2 == 0
+		})
+
+		check := run(t, svc, 21)
+		mustEqual(t, health.StateFlapping, check.State, "unexpected state")
+
+		// changes still within the window keep the check flapping until the
+		// percent state change drops below the low threshold
+		for i := 0; i < 20 && check.State == health.StateFlapping; i++ {
+			check = run(t, svc, 1)
+		}
+		equal(t, health.StateUp, check.State, "check did not stop flapping")
+		if check.Flap.Change >= 25 {
+			t.Errorf("stopped flapping above the low threshold: got=%.2f", check.Flap.Change)
+		}
+	})
+
+	t.Run("occasional failures are not flapping", func(t *testing.T) {
+		svc := newSVC(health.Check{State: health.StatePending}, func(i int) bool { return i%7 != 0 })
+
+		check := run(t, svc, 50)
+		equal(t, false, check.State == health.StateFlapping, "unexpected flapping")
+	})
+
+	t.Run("thresholds are configurable", func(t *testing.T) {
+		svc := newSVC(health.Check{State: health.StatePending, FlapHighThreshold: 100}, alternating)
+
+		check := run(t, svc, 30)
+		equal(t, false, check.State == health.StateFlapping, "flapping above the configured threshold")
+	})
+
+	t.Run("notifications are suppressed while flapping", func(t *testing.T) {
+		var events []health.Event
+		notifier := health.NotifierFunc(func(ctx context.Context, c health.Check, e health.Event) error {
+			events = append(events, e)
+			return nil
+		})
+		svc := newSVC(health.Check{State: health.StatePending}, alternating, health.WithNotifier(notifier))
+
+		run(t, svc, 60)
+
+		// pending => down, followed by down and up alternating until the
+		// check starts flapping
+		mustEqual(t, 21, len(events), "unexpected number of notifications")
+		equal(t, health.StatePending, events[0].From, "unexpected first transition")
+		equal(t, health.StateFlapping, events[20].To, "unexpected last transition")
+		for _, e := range events[:20] {
+			if e.To == health.StateFlapping {
+				t.Fatalf("flapping notified early: %+v", e)
+			}
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCQuery configures the call to the gRPC health checking protocol of a
+// grpc check. The endpoint of the check is the host:port of the server.
+type GRPCQuery struct {
+	// Service is the name of the service checked, the overall health of the
+	// server is checked when it is not set.
+	Service string `json:"service,omitempty"`
+	TLS     bool   `json:"tls,omitempty"`
+}
+
+const AssertionServingStatus = "serving_status"
+
+type grpcProber struct{}
+
+var _ Prober = (*grpcProber)(nil)
+
+// NewGRPCProber provides a prober that calls grpc.health.v1.Health/Check on
+// the endpoint of the check. The serving status of the response is recorded
+// as the code of the result.
+func NewGRPCProber() Prober {
+	return new(grpcProber)
+}
+
+func (p *grpcProber) Probe(ctx context.Context, c Check) Result {
+	res := Result{
+		CheckID: c.ID,
+		Checked: time.Now(),
+	}
+
+	creds := insecure.NewCredentials()
+	if c.GRPC.tls() {
+		creds = credentials.NewTLS(&tls.Config{})
+	}
+	conn, err := grpc.NewClient(c.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: c.GRPC.service(),
+	})
+	res.Duration = Duration(time.Since(res.Checked))
+	if err != nil {
+		res.Error = status.Convert(err).Message()
+		return res
+	}
+
+	res.Code = int32(resp.GetStatus())
+	switch resp.GetStatus() {
+	case healthpb.HealthCheckResponse_SERVING:
+		res.OK = true
+	case healthpb.HealthCheckResponse_UNKNOWN:
+		res.Unknown = true
+		res.Assertion, res.Failure = AssertionServingStatus, "serving status is UNKNOWN"
+	default:
+		res.Assertion, res.Failure = AssertionServingStatus, "serving status is "+resp.GetStatus().String()
+	}
+	return res
+}
+
+func (q *GRPCQuery) service() string {
+	if q == nil {
+		return ""
+	}
+	return q.Service
+}
+
+func (q *GRPCQuery) tls() bool {
+	return q != nil && q.TLS
+}
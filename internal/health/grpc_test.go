@@ -0,0 +1,61 @@
+package health_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestGRPCProber(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	mustNoError(t, err)
+
+	healthSvr := grpchealth.NewServer()
+	healthSvr.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthSvr.SetServingStatus("payments", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthSvr.SetServingStatus("ledger", healthpb.HealthCheckResponse_UNKNOWN)
+
+	svr := grpc.NewServer()
+	healthpb.RegisterHealthServer(svr, healthSvr)
+	go svr.Serve(l)
+	defer svr.Stop()
+
+	tests := []struct {
+		name            string
+		service         string
+		expectedOK      bool
+		expectedUnknown bool
+		expectedCode    int32
+		expectError     bool
+	}{
+		{name: "serving server", expectedOK: true, expectedCode: int32(healthpb.HealthCheckResponse_SERVING)},
+		{name: "not serving service", service: "payments", expectedCode: int32(healthpb.HealthCheckResponse_NOT_SERVING)},
+		{name: "unknown service status", service: "ledger", expectedUnknown: true, expectedCode: int32(healthpb.HealthCheckResponse_UNKNOWN)},
+		{name: "unregistered service", service: "nope", expectError: true},
+	}
+
+	for _, tt := range tests {
+		fn := func(t *testing.T) {
+			res := health.NewGRPCProber().Probe(context.Background(), health.Check{
+				ID:       "id",
+				Type:     health.TypeGRPC,
+				Endpoint: l.Addr().String(),
+				GRPC:     &health.GRPCQuery{Service: tt.service},
+			})
+
+			equal(t, "id", res.CheckID, "unexpected check id")
+			equal(t, tt.expectedOK, res.OK, "unexpected ok: "+res.Error)
+			equal(t, tt.expectedUnknown, res.Unknown, "unexpected unknown")
+			equal(t, tt.expectedCode, res.Code, "unexpected code")
+			equal(t, tt.expectError, res.Error != "", "unexpected error: "+res.Error)
+		}
+
+		t.Run(tt.name, fn)
+	}
+}
@@ -0,0 +1,435 @@
+package health
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type HistoryRepository interface {
+	AppendResult(res Result) error
+	// Results returns the latest limit results of the check, in ascending
+	// time order, that were checked within [from, to]. A limit of zero
+	// returns every result within the range.
+	Results(id string, from, to time.Time, limit int) ([]Result, error)
+	AppendEvent(e Event) error
+	// Events returns the latest limit state transitions of the check, in
+	// ascending time order, that occurred within [from, to]. A limit of zero
+	// returns every event within the range.
+	Events(id string, from, to time.Time, limit int) ([]Event, error)
+	DeleteHistory(id string) error
+}
+
+type memHistoryRepository struct {
+	maxPerCheck int
+
+	mu      sync.Mutex
+	results map[string][]Result
+	events  map[string][]Event
+}
+
+var _ HistoryRepository = (*memHistoryRepository)(nil)
+
+// NewMemoryHistoryRepository creates a history repository that retains the
+// latest maxPerCheck results of every check in memory.
+func NewMemoryHistoryRepository(maxPerCheck int) HistoryRepository {
+	return &memHistoryRepository{
+		maxPerCheck: maxPerCheck,
+		results:     make(map[string][]Result),
+		events:      make(map[string][]Event),
+	}
+}
+
+func (r *memHistoryRepository) AppendResult(res Result) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := r.results[res.CheckID]
+
+	// results are near enough always appended in order, the search
+	// guards against a probe that finished out of order.
+	idx := sort.Search(len(results), func(i int) bool {
+		return results[i].Checked.After(res.Checked)
+	})
+	results = append(results, Result{})
+	copy(results[idx+1:], results[idx:])
+	results[idx] = res
+
+	if over := len(results) - r.maxPerCheck; r.maxPerCheck > 0 && over > 0 {
+		results = append(results[:0:0], results[over:]...)
+	}
+	r.results[res.CheckID] = results
+	return nil
+}
+
+func (r *memHistoryRepository) Results(id string, from, to time.Time, limit int) ([]Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := r.results[id]
+	start := sort.Search(len(results), func(i int) bool {
+		return !results[i].Checked.Before(from)
+	})
+	end := sort.Search(len(results), func(i int) bool {
+		return results[i].Checked.After(to)
+	})
+	if start >= end {
+		return []Result{}, nil
+	}
+	if limit > 0 && end-start > limit {
+		start = end - limit
+	}
+
+	out := make([]Result, end-start)
+	copy(out, results[start:end])
+	return out, nil
+}
+
+func (r *memHistoryRepository) AppendEvent(e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := append(r.events[e.CheckID], e)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	if over := len(events) - r.maxPerCheck; r.maxPerCheck > 0 && over > 0 {
+		events = append(events[:0:0], events[over:]...)
+	}
+	r.events[e.CheckID] = events
+	return nil
+}
+
+func (r *memHistoryRepository) Events(id string, from, to time.Time, limit int) ([]Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return filterEvents(r.events[id], from, to, limit), nil
+}
+
+func (r *memHistoryRepository) DeleteHistory(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.results, id)
+	delete(r.events, id)
+	return nil
+}
+
+type fileHistoryRepository struct {
+	dir       string
+	retention time.Duration
+
+	// mu guards the checks, the history of each check is guarded by the
+	// mu of the check.
+	mu     sync.Mutex
+	checks map[string]*checkHistory
+}
+
+// checkHistory serializes the writes to the files of a check. The files are
+// read without it, as a file is only ever appended to, or replaced by a
+// rename, and a line partially appended is skipped by the reader.
+type checkHistory struct {
+	mu sync.Mutex
+	// oldest is the oldest result persisted for the check, which determines
+	// when the expired results of the check are compacted away.
+	oldest      time.Time
+	oldestKnown bool
+}
+
+var _ HistoryRepository = (*fileHistoryRepository)(nil)
+
+// NewFileHistoryRepository creates a history repository that persists the
+// results, and events, of each check as JSON lines to a file per check in dir.
+// Results and events older than the retention are dropped.
+func NewFileHistoryRepository(dir string, retention time.Duration) (HistoryRepository, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &fileHistoryRepository{
+		dir:       dir,
+		retention: retention,
+		checks:    make(map[string]*checkHistory),
+	}, nil
+}
+
+func (r *fileHistoryRepository) check(id string) *checkHistory {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.checks[id]
+	if !ok {
+		h = new(checkHistory)
+		r.checks[id] = h
+	}
+	return h
+}
+
+func (r *fileHistoryRepository) AppendResult(res Result) error {
+	h := r.check(res.CheckID)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := appendLine(r.path(res.CheckID), res); err != nil {
+		return err
+	}
+	return r.compact(h, res.CheckID, res.Checked)
+}
+
+func appendLine(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// compact rewrites the history of the check without its expired results. To
+// avoid rewriting the file on every append the results are allowed to exceed
+// the retention by a tenth before they are compacted.
+func (r *fileHistoryRepository) compact(h *checkHistory, id string, now time.Time) error {
+	if !h.oldestKnown {
+		// the results are appended in time order, the first is the oldest
+		err := scanResults(r.path(id), func(res Result) bool {
+			h.oldest = res.Checked
+			return false
+		})
+		if err != nil {
+			return err
+		}
+		h.oldestKnown = true
+	}
+
+	cutoff := now.Add(-r.retention)
+	if h.oldest.After(cutoff.Add(-r.retention / 10)) {
+		return nil
+	}
+
+	oldest := now
+	err := r.rewrite(id, r.path(id), func(enc *json.Encoder) error {
+		var err error
+		scanErr := scanResults(r.path(id), func(res Result) bool {
+			if res.Checked.Before(cutoff) {
+				return true
+			}
+			if res.Checked.Before(oldest) {
+				oldest = res.Checked
+			}
+			err = enc.Encode(res)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+		return scanErr
+	})
+	if err != nil {
+		return err
+	}
+
+	// events are few and far between, so they are compacted along with
+	// the results rather than tracked on their own.
+	events, err := r.readEvents(id)
+	if err != nil {
+		return err
+	}
+	if len(events) > 0 && events[0].Time.Before(cutoff) {
+		events = filterEvents(events, cutoff, events[len(events)-1].Time, 0)
+		err := r.rewrite(id, r.eventsPath(id), func(enc *json.Encoder) error {
+			for _, e := range events {
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	h.oldest = oldest
+	return nil
+}
+
+// Results streams the results of the check from its file, holding only those
+// within the range, rather than reading every result of the check.
+func (r *fileHistoryRepository) Results(id string, from, to time.Time, limit int) ([]Result, error) {
+	out := make([]Result, 0)
+	err := scanResults(r.path(id), func(res Result) bool {
+		if res.Checked.Before(from) || res.Checked.After(to) {
+			return true
+		}
+		out = append(out, res)
+		// the results are appended in time order, so only the latest
+		// limit of them are kept, with some slack so they are trimmed
+		// now and then rather than with every result
+		if limit > 0 && len(out) >= 2*limit {
+			out = append(out[:0], out[len(out)-limit:]...)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Checked.Before(out[j].Checked)
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out, nil
+}
+
+// rewrite replaces the file at path with the lines written by fn.
+func (r *fileHistoryRepository) rewrite(id, path string, fn func(enc *json.Encoder) error) error {
+	tmp, err := ioutil.TempFile(r.dir, id+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := fn(json.NewEncoder(w)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (r *fileHistoryRepository) AppendEvent(e Event) error {
+	h := r.check(e.CheckID)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return appendLine(r.eventsPath(e.CheckID), e)
+}
+
+func (r *fileHistoryRepository) Events(id string, from, to time.Time, limit int) ([]Event, error) {
+	events, err := r.readEvents(id)
+	if err != nil {
+		return nil, err
+	}
+	return filterEvents(events, from, to, limit), nil
+}
+
+func (r *fileHistoryRepository) DeleteHistory(id string) error {
+	h := r.check(id)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.oldest, h.oldestKnown = time.Time{}, false
+	for _, path := range []string{r.path(id), r.eventsPath(id)} {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanResults calls fn with the persisted results of the check in the order
+// they were appended, until fn returns false.
+func scanResults(path string, fn func(res Result) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var res Result
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			// a partially written line is the result of a crash mid
+			// append, or of an append in progress, the results around
+			// it are still valid.
+			continue
+		}
+		if !fn(res) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// readEvents provides all the persisted events of the check in ascending time
+// order.
+func (r *fileHistoryRepository) readEvents(id string) ([]Event, error) {
+	f, err := os.Open(r.eventsPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Event{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	events := make([]Event, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events, nil
+}
+
+func (r *fileHistoryRepository) path(id string) string {
+	return filepath.Join(r.dir, id+".jsonl")
+}
+
+func (r *fileHistoryRepository) eventsPath(id string) string {
+	return filepath.Join(r.dir, id+".events.jsonl")
+}
+
+// filterEvents provides the latest limit of the events, which are in ascending
+// time order, that occurred within [from, to].
+func filterEvents(events []Event, from, to time.Time, limit int) []Event {
+	out := make([]Event, 0)
+	for _, e := range events {
+		if e.Time.Before(from) || e.Time.After(to) {
+			continue
+		}
+		out = append(out, e)
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
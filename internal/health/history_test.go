@@ -0,0 +1,327 @@
+package health_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestMemoryHistoryRepository(t *testing.T) {
+	base := time.Unix(1500000000, 0)
+	newResult := func(id string, offset int) health.Result {
+		return health.Result{
+			CheckID: id,
+			Code:    int32(200 + offset),
+			Checked: base.Add(time.Duration(offset) * time.Minute),
+		}
+	}
+
+	t.Run("results", func(t *testing.T) {
+		repo := health.NewMemoryHistoryRepository(100)
+		// appended out of order to verify results are kept time ordered
+		for _, offset := range []int{0, 1, 2, 4, 3, 5, 6, 7, 8, 9} {
+			mustNoError(t, repo.AppendResult(newResult("id", offset)))
+		}
+		mustNoError(t, repo.AppendResult(newResult("other-id", 1)))
+
+		tests := []struct {
+			name          string
+			from, to      time.Time
+			limit         int
+			expectedCodes []int32
+		}{
+			{
+				name:          "all results",
+				from:          base,
+				to:            base.Add(time.Hour),
+				expectedCodes: []int32{200, 201, 202, 203, 204, 205, 206, 207, 208, 209},
+			},
+			{
+				name:          "bounds are inclusive",
+				from:          base.Add(2 * time.Minute),
+				to:            base.Add(4 * time.Minute),
+				expectedCodes: []int32{202, 203, 204},
+			},
+			{
+				name:          "limited to the latest results",
+				from:          base.Add(5 * time.Minute),
+				to:            base.Add(time.Hour),
+				limit:         2,
+				expectedCodes: []int32{208, 209},
+			},
+			{
+				name:          "no results in range",
+				from:          base.Add(time.Hour),
+				to:            base.Add(2 * time.Hour),
+				expectedCodes: []int32{},
+			},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				results, err := repo.Results("id", tt.from, tt.to, tt.limit)
+				mustNoError(t, err)
+
+				mustEqual(t, len(tt.expectedCodes), len(results), "unexpected number of results")
+				for i, code := range tt.expectedCodes {
+					equal(t, "id", results[i].CheckID, "unexpected check id")
+					equal(t, code, results[i].Code, "unexpected result")
+				}
+			}
+
+			t.Run(tt.name, fn)
+		}
+	})
+
+	t.Run("retains only the latest results", func(t *testing.T) {
+		repo := health.NewMemoryHistoryRepository(3)
+		for i := 0; i < 10; i++ {
+			mustNoError(t, repo.AppendResult(newResult("id", i)))
+		}
+
+		results, err := repo.Results("id", base, base.Add(time.Hour), 0)
+		mustNoError(t, err)
+
+		mustEqual(t, 3, len(results), "unexpected number of results")
+		equal(t, int32(207), results[0].Code, "unexpected oldest result")
+		equal(t, int32(209), results[2].Code, "unexpected newest result")
+	})
+
+	t.Run("events", func(t *testing.T) {
+		repo := health.NewMemoryHistoryRepository(10)
+		mustNoError(t, repo.AppendEvent(health.Event{CheckID: "id", Time: base.Add(2 * time.Minute), From: health.StateUp, To: health.StateDown}))
+		mustNoError(t, repo.AppendEvent(health.Event{CheckID: "id", Time: base, To: health.StatePending}))
+		mustNoError(t, repo.AppendEvent(health.Event{CheckID: "id", Time: base.Add(time.Minute), From: health.StatePending, To: health.StateUp}))
+		mustNoError(t, repo.AppendEvent(health.Event{CheckID: "other-id", Time: base, To: health.StatePending}))
+
+		events, err := repo.Events("id", base, base.Add(time.Hour), 0)
+		mustNoError(t, err)
+		mustEqual(t, 3, len(events), "unexpected number of events")
+		equal(t, health.StatePending, events[0].To, "events not in time order")
+		equal(t, health.StateDown, events[2].To, "events not in time order")
+
+		events, err = repo.Events("id", base.Add(time.Minute), base.Add(time.Hour), 1)
+		mustNoError(t, err)
+		mustEqual(t, 1, len(events), "unexpected number of limited events")
+		equal(t, health.StateDown, events[0].To, "limited events not the latest")
+	})
+
+	t.Run("delete history", func(t *testing.T) {
+		repo := health.NewMemoryHistoryRepository(10)
+		mustNoError(t, repo.AppendResult(newResult("id", 0)))
+		mustNoError(t, repo.AppendEvent(health.Event{CheckID: "id", Time: base, To: health.StatePending}))
+
+		mustNoError(t, repo.DeleteHistory("id"))
+
+		results, err := repo.Results("id", base, base.Add(time.Hour), 0)
+		mustNoError(t, err)
+		equal(t, 0, len(results), "history not deleted")
+
+		events, err := repo.Events("id", base, base.Add(time.Hour), 0)
+		mustNoError(t, err)
+		equal(t, 0, len(events), "events not deleted")
+	})
+}
+
+func TestFileHistoryRepository(t *testing.T) {
+	newTempDir := func(t *testing.T) string {
+		t.Helper()
+
+		tmpDir, err := ioutil.TempDir("", "")
+		mustNoError(t, err)
+		return tmpDir
+	}
+
+	base := time.Now().Add(-time.Hour).Round(0).UTC()
+	newResult := func(id string, offset int) health.Result {
+		return health.Result{
+			CheckID:  id,
+			OK:       offset%2 == 0,
+			Code:     int32(200 + offset),
+			Checked:  base.Add(time.Duration(offset) * time.Minute),
+			Duration: health.Duration(time.Duration(offset) * time.Millisecond),
+		}
+	}
+
+	t.Run("results are persisted across instances", func(t *testing.T) {
+		dir := newTempDir(t)
+		defer os.RemoveAll(dir)
+
+		repo, err := health.NewFileHistoryRepository(dir, 24*time.Hour)
+		mustNoError(t, err)
+
+		var expected []health.Result
+		for i := 0; i < 5; i++ {
+			res := newResult("id", i)
+			expected = append(expected, res)
+			mustNoError(t, repo.AppendResult(res))
+		}
+		mustNoError(t, repo.AppendResult(newResult("other-id", 0)))
+
+		repo, err = health.NewFileHistoryRepository(dir, 24*time.Hour)
+		mustNoError(t, err)
+
+		results, err := repo.Results("id", base, base.Add(time.Hour), 0)
+		mustNoError(t, err)
+
+		mustEqual(t, len(expected), len(results), "unexpected number of results")
+		for i := range expected {
+			equal(t, expected[i].Code, results[i].Code, "unexpected code")
+			equal(t, expected[i].OK, results[i].OK, "unexpected ok")
+			equal(t, expected[i].Duration, results[i].Duration, "unexpected duration")
+			equal(t, true, expected[i].Checked.Equal(results[i].Checked), "unexpected checked")
+		}
+
+		results, err = repo.Results("id", base.Add(time.Minute), base.Add(time.Hour), 2)
+		mustNoError(t, err)
+
+		mustEqual(t, 2, len(results), "unexpected number of limited results")
+		equal(t, int32(203), results[0].Code, "unexpected first result")
+		equal(t, int32(204), results[1].Code, "limited results not the latest")
+	})
+
+	t.Run("expired results are compacted away", func(t *testing.T) {
+		dir := newTempDir(t)
+		defer os.RemoveAll(dir)
+
+		repo, err := health.NewFileHistoryRepository(dir, 10*time.Minute)
+		mustNoError(t, err)
+
+		for i := 0; i < 30; i++ {
+			mustNoError(t, repo.AppendResult(newResult("id", i)))
+		}
+
+		results, err := repo.Results("id", base.Add(-time.Hour), base.Add(time.Hour), 0)
+		mustNoError(t, err)
+
+		if len(results) > 12 {
+			t.Fatalf("expired results not compacted: got=%d results", len(results))
+		}
+		equal(t, int32(229), results[len(results)-1].Code, "latest result not retained")
+	})
+
+	t.Run("events are persisted across instances", func(t *testing.T) {
+		dir := newTempDir(t)
+		defer os.RemoveAll(dir)
+
+		repo, err := health.NewFileHistoryRepository(dir, 24*time.Hour)
+		mustNoError(t, err)
+
+		mustNoError(t, repo.AppendResult(newResult("id", 0)))
+		mustNoError(t, repo.AppendEvent(health.Event{CheckID: "id", Time: base, To: health.StatePending, Reason: "check created"}))
+		mustNoError(t, repo.AppendEvent(health.Event{CheckID: "id", Time: base.Add(time.Minute), From: health.StatePending, To: health.StateUp, Reason: "probe succeeded"}))
+
+		repo, err = health.NewFileHistoryRepository(dir, 24*time.Hour)
+		mustNoError(t, err)
+
+		events, err := repo.Events("id", base, base.Add(time.Hour), 0)
+		mustNoError(t, err)
+		mustEqual(t, 2, len(events), "unexpected number of events")
+		equal(t, health.StatePending, events[1].From, "unexpected previous state")
+		equal(t, health.StateUp, events[1].To, "unexpected new state")
+		equal(t, "probe succeeded", events[1].Reason, "unexpected reason")
+
+		results, err := repo.Results("id", base, base.Add(time.Hour), 0)
+		mustNoError(t, err)
+		equal(t, 1, len(results), "events mixed in with the results")
+	})
+
+	t.Run("expired events are compacted away", func(t *testing.T) {
+		dir := newTempDir(t)
+		defer os.RemoveAll(dir)
+
+		repo, err := health.NewFileHistoryRepository(dir, 10*time.Minute)
+		mustNoError(t, err)
+
+		mustNoError(t, repo.AppendEvent(health.Event{CheckID: "id", Time: base, To: health.StatePending}))
+		for i := 0; i < 30; i++ {
+			mustNoError(t, repo.AppendResult(newResult("id", i)))
+		}
+		mustNoError(t, repo.AppendEvent(health.Event{CheckID: "id", Time: base.Add(29 * time.Minute), From: health.StatePending, To: health.StateUp}))
+
+		events, err := repo.Events("id", base.Add(-time.Hour), base.Add(time.Hour), 0)
+		mustNoError(t, err)
+		mustEqual(t, 1, len(events), "expired events not compacted")
+		equal(t, health.StateUp, events[0].To, "latest event not retained")
+	})
+
+	t.Run("partially written results are skipped", func(t *testing.T) {
+		dir := newTempDir(t)
+		defer os.RemoveAll(dir)
+
+		repo, err := health.NewFileHistoryRepository(dir, 24*time.Hour)
+		mustNoError(t, err)
+
+		mustNoError(t, repo.AppendResult(newResult("id", 0)))
+
+		f, err := os.OpenFile(filepath.Join(dir, "id.jsonl"), os.O_WRONLY|os.O_APPEND, 0644)
+		mustNoError(t, err)
+		_, err = f.WriteString(`{"check_id":"id","co` + "\n")
+		mustNoError(t, err)
+		mustNoError(t, f.Close())
+
+		mustNoError(t, repo.AppendResult(newResult("id", 1)))
+
+		results, err := repo.Results("id", base, base.Add(time.Hour), 0)
+		mustNoError(t, err)
+		equal(t, 2, len(results), "unexpected number of results")
+	})
+
+	t.Run("results are read while results are appended and compacted", func(t *testing.T) {
+		dir := newTempDir(t)
+		defer os.RemoveAll(dir)
+
+		repo, err := health.NewFileHistoryRepository(dir, 10*time.Minute)
+		mustNoError(t, err)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 60; i++ {
+				if err := repo.AppendResult(newResult("id", i)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+
+		for {
+			select {
+			case <-done:
+				results, err := repo.Results("id", base.Add(-time.Hour), base.Add(time.Hour), 0)
+				mustNoError(t, err)
+				equal(t, int32(259), results[len(results)-1].Code, "latest result not retained")
+				return
+			default:
+			}
+
+			results, err := repo.Results("id", base.Add(-time.Hour), base.Add(time.Hour), 0)
+			mustNoError(t, err)
+			for i := 1; i < len(results); i++ {
+				if results[i].Checked.Before(results[i-1].Checked) {
+					t.Fatalf("results out of order: %#v", results)
+				}
+			}
+		}
+	})
+
+	t.Run("delete history", func(t *testing.T) {
+		dir := newTempDir(t)
+		defer os.RemoveAll(dir)
+
+		repo, err := health.NewFileHistoryRepository(dir, 24*time.Hour)
+		mustNoError(t, err)
+
+		mustNoError(t, repo.AppendResult(newResult("id", 0)))
+		mustNoError(t, repo.DeleteHistory("id"))
+		mustNoError(t, repo.DeleteHistory("id"))
+
+		results, err := repo.Results("id", base, base.Add(time.Hour), 0)
+		mustNoError(t, err)
+		equal(t, 0, len(results), "history not deleted")
+	})
+}
@@ -1,12 +1,14 @@
 package health
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"path"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type HTTPServer struct {
@@ -24,7 +26,7 @@ func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "route not found", http.StatusNotFound)
 		return
 	}
-	r.URL.Path = path.Clean("/" + strings.Trim(r.URL.Path, "/health"))
+	r.URL.Path = path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/health"))
 	s.routes(w, r)
 }
 
@@ -51,25 +53,144 @@ func (s *HTTPServer) routes(w http.ResponseWriter, r *http.Request) {
 			default:
 				http.Error(w, "unsupported HTTP method", http.StatusMethodNotAllowed)
 			}
+		case len(parts) == 4 && parts[3] == "results": // route => /checks/:id/results
+			switch r.Method {
+			case http.MethodGet:
+				s.results(w, r, parts[2])
+			default:
+				http.Error(w, "unsupported HTTP method", http.StatusMethodNotAllowed)
+			}
+		case len(parts) == 4 && parts[3] == "events": // route => /checks/:id/events
+			switch r.Method {
+			case http.MethodGet:
+				s.events(w, r, parts[2])
+			default:
+				http.Error(w, "unsupported HTTP method", http.StatusMethodNotAllowed)
+			}
+		case len(parts) == 4 && parts[3] == "uptime": // route => /checks/:id/uptime
+			switch r.Method {
+			case http.MethodGet:
+				s.uptime(w, r, parts[2])
+			default:
+				http.Error(w, "unsupported HTTP method", http.StatusMethodNotAllowed)
+			}
+		case len(parts) == 4 && parts[3] == "run": // route => /checks/:id/run
+			switch r.Method {
+			case http.MethodPost:
+				s.run(w, r, parts[2])
+			default:
+				http.Error(w, "unsupported HTTP method", http.StatusMethodNotAllowed)
+			}
+		case len(parts) == 4 && (parts[3] == "pause" || parts[3] == "resume"): // route => /checks/:id/pause, /checks/:id/resume
+			switch r.Method {
+			case http.MethodPost:
+				s.pause(w, r, parts[2], parts[3] == "pause")
+			default:
+				http.Error(w, "unsupported HTTP method", http.StatusMethodNotAllowed)
+			}
+		case len(parts) == 4 && parts[3] == "stats": // route => /checks/:id/stats
+			switch r.Method {
+			case http.MethodGet:
+				s.stats(w, r, parts[2])
+			default:
+				http.Error(w, "unsupported HTTP method", http.StatusMethodNotAllowed)
+			}
 		default:
 			http.Error(w, "route not supported", http.StatusNotFound)
 		}
+	case r.URL.Path == "/maintenance":
+		switch r.Method {
+		case http.MethodGet:
+			s.listMaintenance(w, r)
+		case http.MethodPost:
+			s.createMaintenance(w, r)
+		default:
+			http.Error(w, "unsupported HTTP method", http.StatusMethodNotAllowed)
+		}
+	case strings.HasPrefix(r.URL.Path, "/maintenance/"):
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 3 { // route => /maintenance/:id
+			http.Error(w, "route not supported", http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			s.readMaintenance(w, r, parts[2])
+		case http.MethodDelete:
+			s.deleteMaintenance(w, r, parts[2])
+		default:
+			http.Error(w, "unsupported HTTP method", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.Error(w, "route not found", http.StatusNotFound)
 	}
 }
 
 func (s *HTTPServer) create(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		Endpoint string `json:"endpoint"`
+		Type     string   `json:"type"`
+		Endpoint string   `json:"endpoint"`
+		Interval Duration `json:"interval"`
+		Timeout  Duration `json:"timeout"`
+		Jitter   Duration `json:"jitter"`
+
+		Labels map[string]string `json:"labels"`
+
+		Request *Request     `json:"request"`
+		Expect  *Expectation `json:"expect"`
+		DNS     *DNSQuery    `json:"dns"`
+		GRPC    *GRPCQuery   `json:"grpc"`
+
+		WebSocket       *WebSocketQuery `json:"websocket"`
+		CertWarningDays int             `json:"cert_warning_days"`
+
+		Retries          int      `json:"retries"`
+		RetryBackoff     Duration `json:"retry_backoff"`
+		FailureThreshold int      `json:"failure_threshold"`
+		SuccessThreshold int      `json:"success_threshold"`
+
+		FlapLowThreshold  float64 `json:"flap_low_threshold"`
+		FlapHighThreshold float64 `json:"flap_high_threshold"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	c, err := s.svc.Create(body.Endpoint)
+	c, err := s.svc.Create(Check{
+		Type:     body.Type,
+		Endpoint: body.Endpoint,
+		Labels:   body.Labels,
+		Interval: body.Interval,
+		Timeout:  body.Timeout,
+		Jitter:   body.Jitter,
+		Request:  body.Request,
+		Expect:   body.Expect,
+		DNS:      body.DNS,
+		GRPC:     body.GRPC,
+
+		WebSocket:       body.WebSocket,
+		CertWarningDays: body.CertWarningDays,
+
+		Retries:          body.Retries,
+		RetryBackoff:     body.RetryBackoff,
+		FailureThreshold: body.FailureThreshold,
+		SuccessThreshold: body.SuccessThreshold,
+
+		FlapLowThreshold:  body.FlapLowThreshold,
+		FlapHighThreshold: body.FlapHighThreshold,
+	})
 	if err != nil {
 		switch err {
-		case errInvalidEndpoint, errEndpointExists:
+		case errInvalidEndpoint, errInvalidType, errInvalidTCPEndpoint, errInvalidDNSName,
+			errInvalidRecordType, errInvalidResolver, errInvalidWebSocketEndpoint,
+			errInvalidInterval, errInvalidTimeout, errInvalidJitter,
+			errInvalidMethod, errInvalidAuth, errInvalidSecretRef, errInvalidSecretHeader, errInvalidCertWarningDays,
+			errInvalidRetries, errInvalidRetryBackoff, errInvalidThreshold, errInvalidFlapThreshold,
+			errInvalidLabels, errInvalidCodes, errInvalidBodyRegex, errInvalidJSONPath, errEndpointExists,
+			errUnappliedRequest, errUnappliedExpect, errUnappliedCertWarning, errUnappliedDNS, errUnappliedGRPC,
+			errUnappliedWebSocket, errInvalidWebSocketRequest, errInvalidWebSocketReply,
+			errSQLiteInlineSecret:
 			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 		default:
 			http.Error(w, "unexpected error", http.StatusInternalServerError)
@@ -97,6 +218,11 @@ func (s *HTTPServer) list(w http.ResponseWriter, r *http.Request) {
 
 	total, currentPage, c := s.svc.List(page)
 
+	items := make([]Check, 0, len(c))
+	for _, check := range c {
+		items = append(items, check.Redacted())
+	}
+
 	w.WriteHeader(http.StatusOK)
 
 	body := struct {
@@ -105,7 +231,7 @@ func (s *HTTPServer) list(w http.ResponseWriter, r *http.Request) {
 		Total int     `json:"total"`
 		Size  int     `json:"size"`
 	}{
-		Items: c,
+		Items: items,
 		Page:  currentPage,
 		Total: total,
 		Size:  10,
@@ -134,7 +260,7 @@ func (s *HTTPServer) read(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = prettyEncoder(w).Encode(check)
+	err = prettyEncoder(w).Encode(check.Redacted())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -157,6 +283,323 @@ func (s *HTTPServer) delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+const (
+	defaultRunTimeout = 30 * time.Second
+	maxRunTimeout     = time.Minute
+)
+
+func (s *HTTPServer) run(w http.ResponseWriter, r *http.Request, id string) {
+	timeout := defaultRunTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		var err error
+		timeout, err = parseDuration(v)
+		if err != nil || timeout <= 0 || timeout > maxRunTimeout {
+			http.Error(w, "timeout must be a positive duration no greater than 1m", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	check, err := s.svc.Run(ctx, id)
+	if err != nil {
+		switch err {
+		case errInvalidID:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		case errCheckNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		case errCheckPaused:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case context.DeadlineExceeded:
+			http.Error(w, "timed out waiting for the probe to complete", http.StatusGatewayTimeout)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = prettyEncoder(w).Encode(check.Redacted())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *HTTPServer) pause(w http.ResponseWriter, r *http.Request, id string, paused bool) {
+	setPaused := s.svc.Resume
+	if paused {
+		setPaused = s.svc.Pause
+	}
+
+	check, err := setPaused(id)
+	if err != nil {
+		switch err {
+		case errInvalidID:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		case errCheckNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = prettyEncoder(w).Encode(check.Redacted())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *HTTPServer) results(w http.ResponseWriter, r *http.Request, id string) {
+	from, to, limit, ok := rangeParams(w, r)
+	if !ok {
+		return
+	}
+
+	results, err := s.svc.Results(id, from, to, limit)
+	if err != nil {
+		switch err {
+		case errInvalidID, errInvalidTimeRange, errInvalidLimit:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errCheckNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	body := struct {
+		Items []Result `json:"items"`
+		Total int      `json:"total"`
+	}{
+		Items: results,
+		Total: len(results),
+	}
+
+	err = prettyEncoder(w).Encode(body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *HTTPServer) events(w http.ResponseWriter, r *http.Request, id string) {
+	from, to, limit, ok := rangeParams(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := s.svc.Events(id, from, to, limit)
+	if err != nil {
+		switch err {
+		case errInvalidID, errInvalidTimeRange, errInvalidLimit:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errCheckNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	body := struct {
+		Items []Event `json:"items"`
+		Total int     `json:"total"`
+	}{
+		Items: events,
+		Total: len(events),
+	}
+
+	err = prettyEncoder(w).Encode(body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+// rangeParams parses the from, to and limit params of the request, responding
+// with a bad request when they are invalid.
+func rangeParams(w http.ResponseWriter, r *http.Request) (from, to time.Time, limit int, ok bool) {
+	params := r.URL.Query()
+
+	for param, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := params.Get(param)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, param+" must be a RFC3339 timestamp", http.StatusBadRequest)
+			return from, to, 0, false
+		}
+		*t = parsed
+	}
+
+	if v := params.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "limit must be an integer", http.StatusBadRequest)
+			return from, to, 0, false
+		}
+	}
+	return from, to, limit, true
+}
+
+func (s *HTTPServer) uptime(w http.ResponseWriter, r *http.Request, id string) {
+	window, ok := windowParam(w, r)
+	if !ok {
+		return
+	}
+
+	uptime, err := s.svc.Uptime(id, window)
+	if err != nil {
+		switch err {
+		case errInvalidID, errInvalidWindow:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errCheckNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = prettyEncoder(w).Encode(uptime)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *HTTPServer) stats(w http.ResponseWriter, r *http.Request, id string) {
+	window, ok := windowParam(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := s.svc.Stats(id, window)
+	if err != nil {
+		switch err {
+		case errInvalidID, errInvalidWindow:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errCheckNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = prettyEncoder(w).Encode(stats)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *HTTPServer) createMaintenance(w http.ResponseWriter, r *http.Request) {
+	var body MaintenanceWindow
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	window, err := s.svc.CreateMaintenance(body)
+	if err != nil {
+		switch err {
+		case errInvalidMaintenanceTarget, errInvalidMaintenanceTime, errInvalidMaintenanceDuration,
+			errInvalidCron, errInvalidTimezone, errInvalidLabels:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, "unexpected error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := prettyEncoder(w).Encode(window); err != nil {
+		http.Error(w, "unexpected error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *HTTPServer) listMaintenance(w http.ResponseWriter, r *http.Request) {
+	windows, err := s.svc.ListMaintenance()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body := struct {
+		Items []MaintenanceWindow `json:"items"`
+		Total int                 `json:"total"`
+	}{
+		Items: append([]MaintenanceWindow{}, windows...),
+		Total: len(windows),
+	}
+
+	err = prettyEncoder(w).Encode(body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *HTTPServer) readMaintenance(w http.ResponseWriter, r *http.Request, id string) {
+	window, err := s.svc.ReadMaintenance(id)
+	if err != nil {
+		switch err {
+		case errInvalidMaintenanceID:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		case errMaintenanceNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = prettyEncoder(w).Encode(window)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *HTTPServer) deleteMaintenance(w http.ResponseWriter, r *http.Request, id string) {
+	if err := s.svc.DeleteMaintenance(id); err != nil {
+		switch err {
+		case errInvalidMaintenanceID:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		case errMaintenanceNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func windowParam(w http.ResponseWriter, r *http.Request) (time.Duration, bool) {
+	v := r.URL.Query().Get("window")
+	if v == "" {
+		return 0, true
+	}
+
+	window, err := parseDuration(v)
+	if err != nil {
+		http.Error(w, "window must be a duration, i.e. 1h, 7d or 30d", http.StatusBadRequest)
+		return 0, false
+	}
+	return window, true
+}
+
 func prettyEncoder(w io.Writer) *json.Encoder {
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "\t")
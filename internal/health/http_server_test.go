@@ -2,12 +2,16 @@ package health_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/jsteenb2/health/internal/health"
 )
@@ -15,12 +19,12 @@ import (
 func TestHTTPServer(t *testing.T) {
 	t.Run("create new endpoint check", func(t *testing.T) {
 		t.Run("happy path", func(t *testing.T) {
+			var created health.Check
 			svc := &fakeSVC{
-				createFn: func(endpoint string) (health.Check, error) {
-					return health.Check{
-						ID:       "id",
-						Endpoint: endpoint,
-					}, nil
+				createFn: func(check health.Check) (health.Check, error) {
+					created = check
+					check.ID = "id"
+					return check, nil
 				},
 			}
 
@@ -28,8 +32,21 @@ func TestHTTPServer(t *testing.T) {
 
 			body := struct {
 				Endpoint string `json:"endpoint"`
+				Interval string `json:"interval"`
+				Timeout  string `json:"timeout"`
+				Jitter   string `json:"jitter"`
+
+				Expect health.Expectation `json:"expect"`
 			}{
 				Endpoint: "https://www.example.com",
+				Interval: "5s",
+				Timeout:  "2s",
+				Jitter:   "500ms",
+				Expect: health.Expectation{
+					Codes:     "200",
+					JSONPath:  "$.status",
+					JSONValue: "ok",
+				},
 			}
 			req := httptest.NewRequest(http.MethodPost, "/health/checks", encodeBody(t, body))
 			rec := httptest.NewRecorder()
@@ -43,6 +60,57 @@ func TestHTTPServer(t *testing.T) {
 
 			equal(t, "id", m["id"], "invalid id")
 			equal(t, body.Endpoint, m["endpoint"], "invalid endpoint")
+
+			equal(t, health.Duration(5*time.Second), created.Interval, "invalid interval")
+			equal(t, health.Duration(2*time.Second), created.Timeout, "invalid timeout")
+			equal(t, health.Duration(500*time.Millisecond), created.Jitter, "invalid jitter")
+			mustEqual(t, true, created.Expect != nil, "missing expectation")
+			equal(t, body.Expect.Codes, created.Expect.Codes, "invalid expected codes")
+			equal(t, body.Expect.JSONPath, created.Expect.JSONPath, "invalid expected json path")
+			equal(t, body.Expect.JSONValue, created.Expect.JSONValue, "invalid expected json value")
+		})
+
+		t.Run("invalid schedule", func(t *testing.T) {
+			svr := health.NewHTTPServer(&fakeSVC{})
+
+			body := map[string]string{
+				"endpoint": "https://www.example.com",
+				"interval": "not a duration",
+			}
+			req := httptest.NewRequest(http.MethodPost, "/health/checks", encodeBody(t, body))
+			rec := httptest.NewRecorder()
+
+			svr.ServeHTTP(rec, req)
+
+			mustEqual(t, http.StatusBadRequest, rec.Code, "bad status code")
+		})
+
+		t.Run("invalid check", func(t *testing.T) {
+			svr := health.NewHTTPServer(health.NewSVC(&fakeRepo{}))
+
+			tests := []struct {
+				name  string
+				check map[string]interface{}
+			}{
+				{
+					name: "invalid secret header",
+					check: map[string]interface{}{
+						"endpoint": "https://www.example.com",
+						"request":  map[string]interface{}{"secret_headers": map[string]string{"X-Auth": "s3cr3t"}},
+					},
+				},
+			}
+
+			for _, tt := range tests {
+				fn := func(t *testing.T) {
+					rec := httptest.NewRecorder()
+					svr.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/health/checks", encodeBody(t, tt.check)))
+
+					equal(t, http.StatusUnprocessableEntity, rec.Code, "bad status code")
+				}
+
+				t.Run(tt.name, fn)
+			}
 		})
 	})
 
@@ -98,7 +166,7 @@ func TestHTTPServer(t *testing.T) {
 				readFn: func(id string) (health.Check, error) {
 					return health.Check{
 						ID:       id,
-						Status:   "OK",
+						State:    health.StateUp,
 						Code:     200,
 						Endpoint: "http://example.com",
 						Checked:  10,
@@ -122,7 +190,7 @@ func TestHTTPServer(t *testing.T) {
 
 			expectedCheck := health.Check{
 				ID:       endpointID,
-				Status:   "OK",
+				State:    health.StateUp,
 				Code:     200,
 				Endpoint: "http://example.com",
 				Checked:  10,
@@ -132,6 +200,477 @@ func TestHTTPServer(t *testing.T) {
 		})
 	})
 
+	t.Run("run", func(t *testing.T) {
+		t.Run("returns the freshly probed check", func(t *testing.T) {
+			svc := &fakeSVC{
+				runFn: func(ctx context.Context, id string) (health.Check, error) {
+					if _, ok := ctx.Deadline(); !ok {
+						t.Error("run is not bounded by a timeout")
+					}
+					return health.Check{ID: id, State: health.StateUp, Code: 200, Checked: 10}, nil
+				},
+			}
+
+			svr := health.NewHTTPServer(svc)
+
+			req := httptest.NewRequest(http.MethodPost, "/health/checks/id-1/run", nil)
+			rec := httptest.NewRecorder()
+
+			svr.ServeHTTP(rec, req)
+
+			mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+
+			var resp health.Check
+			decodeBody(t, rec.Body, &resp)
+
+			equal(t, health.Check{ID: "id-1", State: health.StateUp, Code: 200, Checked: 10}, resp, "unexpected check")
+		})
+
+		t.Run("errors", func(t *testing.T) {
+			tests := []struct {
+				name         string
+				rawQuery     string
+				runErr       error
+				expectedCode int
+			}{
+				{name: "invalid timeout", rawQuery: "timeout=1h", expectedCode: http.StatusBadRequest},
+				{name: "timed out", rawQuery: "timeout=1ms", runErr: context.DeadlineExceeded, expectedCode: http.StatusGatewayTimeout},
+				{name: "unexpected error", runErr: errors.New("unexpected"), expectedCode: http.StatusInternalServerError},
+			}
+
+			for _, tt := range tests {
+				fn := func(t *testing.T) {
+					svc := &fakeSVC{
+						runFn: func(ctx context.Context, id string) (health.Check, error) {
+							return health.Check{}, tt.runErr
+						},
+					}
+
+					svr := health.NewHTTPServer(svc)
+
+					req := httptest.NewRequest(http.MethodPost, "/health/checks/id-1/run?"+tt.rawQuery, nil)
+					rec := httptest.NewRecorder()
+
+					svr.ServeHTTP(rec, req)
+
+					equal(t, tt.expectedCode, rec.Code, "bad status code")
+				}
+
+				t.Run(tt.name, fn)
+			}
+		})
+
+		t.Run("only supports POST", func(t *testing.T) {
+			svr := health.NewHTTPServer(&fakeSVC{})
+
+			req := httptest.NewRequest(http.MethodGet, "/health/checks/id-1/run", nil)
+			rec := httptest.NewRecorder()
+
+			svr.ServeHTTP(rec, req)
+
+			equal(t, http.StatusMethodNotAllowed, rec.Code, "bad status code")
+		})
+	})
+
+	t.Run("pause and resume", func(t *testing.T) {
+		svc := &fakeSVC{
+			pauseFn: func(id string) (health.Check, error) {
+				return health.Check{ID: id, State: health.StatePaused}, nil
+			},
+			resumeFn: func(id string) (health.Check, error) {
+				return health.Check{ID: id, State: health.StatePending}, nil
+			},
+		}
+		svr := health.NewHTTPServer(svc)
+
+		tests := []struct {
+			path          string
+			expectedState health.State
+		}{
+			{path: "/health/checks/id-1/pause", expectedState: health.StatePaused},
+			{path: "/health/checks/id-1/resume", expectedState: health.StatePending},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+				rec := httptest.NewRecorder()
+
+				svr.ServeHTTP(rec, req)
+
+				mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+
+				var resp health.Check
+				decodeBody(t, rec.Body, &resp)
+				equal(t, health.Check{ID: "id-1", State: tt.expectedState}, resp, "unexpected check")
+			}
+
+			t.Run(tt.path, fn)
+		}
+
+		t.Run("unsupported method", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/health/checks/id-1/pause", nil)
+			rec := httptest.NewRecorder()
+
+			svr.ServeHTTP(rec, req)
+
+			equal(t, http.StatusMethodNotAllowed, rec.Code, "bad status code")
+		})
+	})
+
+	t.Run("events", func(t *testing.T) {
+		t.Run("happy path", func(t *testing.T) {
+			from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			svc := &fakeSVC{
+				eventsFn: func(id string, f, tt time.Time, limit int) ([]health.Event, error) {
+					equal(t, "id-1", id, "unexpected id")
+					equal(t, true, from.Equal(f), "unexpected from")
+					equal(t, true, tt.IsZero(), "unexpected to")
+					equal(t, 0, limit, "unexpected limit")
+					return []health.Event{
+						{CheckID: id, Time: f, To: health.StatePending, Reason: "check created"},
+						{CheckID: id, Time: f.Add(time.Minute), From: health.StatePending, To: health.StateDown, Reason: "codes: status code 503 is not one of the expected codes"},
+					}, nil
+				},
+			}
+
+			svr := health.NewHTTPServer(svc)
+
+			req := httptest.NewRequest(http.MethodGet, "/health/checks/id-1/events?from="+from.Format(time.RFC3339), nil)
+			rec := httptest.NewRecorder()
+
+			svr.ServeHTTP(rec, req)
+
+			mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+
+			var resp struct {
+				Items []health.Event `json:"items"`
+				Total int            `json:"total"`
+			}
+			decodeBody(t, rec.Body, &resp)
+
+			equal(t, 2, resp.Total, "unexpected total")
+			mustEqual(t, 2, len(resp.Items), "unexpected number of events")
+			equal(t, health.StatePending, resp.Items[1].From, "unexpected previous state")
+			equal(t, health.StateDown, resp.Items[1].To, "unexpected new state")
+		})
+
+		t.Run("invalid query params", func(t *testing.T) {
+			svr := health.NewHTTPServer(&fakeSVC{})
+
+			req := httptest.NewRequest(http.MethodGet, "/health/checks/id-1/events?limit=ten", nil)
+			rec := httptest.NewRecorder()
+
+			svr.ServeHTTP(rec, req)
+
+			equal(t, http.StatusBadRequest, rec.Code, "bad status code")
+		})
+	})
+
+	t.Run("results", func(t *testing.T) {
+		// the id ends in characters of "/health" to guard against the prefix
+		// being trimmed as a cutset
+		endpointID := "id-health"
+
+		t.Run("happy path", func(t *testing.T) {
+			from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			to := from.Add(time.Hour)
+
+			svc := &fakeSVC{
+				resultsFn: func(id string, f, tt time.Time, limit int) ([]health.Result, error) {
+					equal(t, endpointID, id, "unexpected id")
+					equal(t, true, from.Equal(f), "unexpected from")
+					equal(t, true, to.Equal(tt), "unexpected to")
+					equal(t, 5, limit, "unexpected limit")
+					return []health.Result{
+						{CheckID: id, OK: true, Code: 200, Checked: f, Duration: health.Duration(10 * time.Millisecond)},
+						{CheckID: id, Code: 500, Checked: tt, Duration: health.Duration(time.Second)},
+					}, nil
+				},
+			}
+
+			svr := health.NewHTTPServer(svc)
+
+			u := url.URL{Path: "/health/checks/" + endpointID + "/results"}
+			params := u.Query()
+			params.Set("from", from.Format(time.RFC3339))
+			params.Set("to", to.Format(time.RFC3339))
+			params.Set("limit", "5")
+			u.RawQuery = params.Encode()
+
+			req := httptest.NewRequest(http.MethodGet, u.String(), nil)
+			rec := httptest.NewRecorder()
+
+			svr.ServeHTTP(rec, req)
+
+			mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+
+			var resp struct {
+				Items []health.Result `json:"items"`
+				Total int             `json:"total"`
+			}
+			decodeBody(t, rec.Body, &resp)
+
+			equal(t, 2, resp.Total, "unexpected total")
+			mustEqual(t, 2, len(resp.Items), "unexpected number of results")
+			equal(t, int32(200), resp.Items[0].Code, "unexpected first result")
+			equal(t, health.Duration(10*time.Millisecond), resp.Items[0].Duration, "unexpected duration")
+			equal(t, int32(500), resp.Items[1].Code, "unexpected last result")
+		})
+
+		t.Run("invalid query params", func(t *testing.T) {
+			for _, rawQuery := range []string{"from=yesterday", "to=1234", "limit=ten"} {
+				svr := health.NewHTTPServer(&fakeSVC{})
+
+				req := httptest.NewRequest(http.MethodGet, "/health/checks/"+endpointID+"/results?"+rawQuery, nil)
+				rec := httptest.NewRecorder()
+
+				svr.ServeHTTP(rec, req)
+
+				equal(t, http.StatusBadRequest, rec.Code, "bad status code for "+rawQuery)
+			}
+		})
+	})
+
+	t.Run("uptime", func(t *testing.T) {
+		t.Run("happy path", func(t *testing.T) {
+			availability := 99.5
+			svc := &fakeSVC{
+				uptimeFn: func(id string, window time.Duration) (health.Uptime, error) {
+					equal(t, 7*24*time.Hour, window, "unexpected window")
+					return health.Uptime{
+						CheckID:      id,
+						Window:       health.Duration(window),
+						Probes:       100,
+						Availability: &availability,
+						Downtime:     health.Duration(time.Hour),
+						Incidents:    2,
+						MTTR:         health.Duration(30 * time.Minute),
+					}, nil
+				},
+			}
+
+			svr := health.NewHTTPServer(svc)
+
+			req := httptest.NewRequest(http.MethodGet, "/health/checks/id-1/uptime?window=7d", nil)
+			rec := httptest.NewRecorder()
+
+			svr.ServeHTTP(rec, req)
+
+			mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+
+			var resp health.Uptime
+			decodeBody(t, rec.Body, &resp)
+
+			equal(t, "id-1", resp.CheckID, "unexpected check id")
+			equal(t, 100, resp.Probes, "unexpected probes")
+			equal(t, availability, *resp.Availability, "unexpected availability")
+			equal(t, health.Duration(time.Hour), resp.Downtime, "unexpected downtime")
+			equal(t, 2, resp.Incidents, "unexpected incidents")
+			equal(t, health.Duration(30*time.Minute), resp.MTTR, "unexpected mttr")
+		})
+
+		t.Run("invalid window", func(t *testing.T) {
+			svr := health.NewHTTPServer(&fakeSVC{})
+
+			req := httptest.NewRequest(http.MethodGet, "/health/checks/id-1/uptime?window=week", nil)
+			rec := httptest.NewRecorder()
+
+			svr.ServeHTTP(rec, req)
+
+			mustEqual(t, http.StatusBadRequest, rec.Code, "bad status code")
+		})
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		svc := &fakeSVC{
+			statsFn: func(id string, window time.Duration) (health.LatencyStats, error) {
+				equal(t, 6*time.Hour, window, "unexpected window")
+				return health.LatencyStats{
+					CheckID: id,
+					Window:  health.Duration(window),
+					Count:   1000,
+					Min:     health.Duration(time.Millisecond),
+					Max:     health.Duration(time.Second),
+					Mean:    health.Duration(50 * time.Millisecond),
+					P50:     health.Duration(40 * time.Millisecond),
+					P90:     health.Duration(90 * time.Millisecond),
+					P95:     health.Duration(120 * time.Millisecond),
+					P99:     health.Duration(400 * time.Millisecond),
+				}, nil
+			},
+		}
+
+		svr := health.NewHTTPServer(svc)
+
+		req := httptest.NewRequest(http.MethodGet, "/health/checks/id-1/stats?window=6h", nil)
+		rec := httptest.NewRecorder()
+
+		svr.ServeHTTP(rec, req)
+
+		mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+
+		var resp map[string]interface{}
+		decodeBody(t, rec.Body, &resp)
+
+		equal(t, "id-1", resp["check_id"], "unexpected check id")
+		equal(t, float64(1000), resp["count"], "unexpected count")
+		equal(t, "1ms", resp["min"], "unexpected min")
+		equal(t, "1s", resp["max"], "unexpected max")
+		equal(t, "40ms", resp["p50"], "unexpected p50")
+		equal(t, "400ms", resp["p99"], "unexpected p99")
+	})
+
+	t.Run("maintenance", func(t *testing.T) {
+		svr := health.NewHTTPServer(health.NewSVC(&fakeRepo{}))
+
+		do := func(t *testing.T, method, path string, body io.Reader) *httptest.ResponseRecorder {
+			t.Helper()
+
+			rec := httptest.NewRecorder()
+			svr.ServeHTTP(rec, httptest.NewRequest(method, path, body))
+			return rec
+		}
+
+		t.Run("create with invalid window", func(t *testing.T) {
+			tests := []struct {
+				name   string
+				window map[string]interface{}
+			}{
+				{name: "no targets", window: map[string]interface{}{"cron": "0 2 * * 0", "duration": "1h"}},
+				{name: "invalid cron", window: map[string]interface{}{"check_ids": []string{"id-1"}, "cron": "0 25 * * *", "duration": "1h"}},
+				{name: "recurring without duration", window: map[string]interface{}{"check_ids": []string{"id-1"}, "cron": "0 2 * * 0"}},
+				{name: "one-off without end", window: map[string]interface{}{"labels": map[string]string{"team": "db"}, "start": "2020-01-01T00:00:00Z"}},
+				{name: "invalid timezone", window: map[string]interface{}{"check_ids": []string{"id-1"}, "cron": "0 2 * * 0", "duration": "1h", "timezone": "Mars/Olympus"}},
+			}
+
+			for _, tt := range tests {
+				fn := func(t *testing.T) {
+					rec := do(t, http.MethodPost, "/health/maintenance", encodeBody(t, tt.window))
+
+					equal(t, http.StatusUnprocessableEntity, rec.Code, "bad status code")
+				}
+
+				t.Run(tt.name, fn)
+			}
+		})
+
+		var created health.MaintenanceWindow
+		t.Run("create", func(t *testing.T) {
+			rec := do(t, http.MethodPost, "/health/maintenance", encodeBody(t, map[string]interface{}{
+				"name":     "weekly db upgrades",
+				"labels":   map[string]string{"team": "db"},
+				"cron":     "0 2 * * 0",
+				"duration": "2h",
+			}))
+
+			mustEqual(t, http.StatusCreated, rec.Code, "bad status code")
+
+			decodeBody(t, rec.Body, &created)
+			equal(t, 32, len(created.ID), "unexpected id")
+			equal(t, "weekly db upgrades", created.Name, "unexpected name")
+			equal(t, health.Duration(2*time.Hour), created.Duration, "unexpected duration")
+		})
+
+		t.Run("list", func(t *testing.T) {
+			rec := do(t, http.MethodGet, "/health/maintenance", nil)
+
+			mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+
+			var resp struct {
+				Items []health.MaintenanceWindow `json:"items"`
+				Total int                        `json:"total"`
+			}
+			decodeBody(t, rec.Body, &resp)
+			equal(t, 1, resp.Total, "unexpected total")
+			mustEqual(t, 1, len(resp.Items), "unexpected number of windows")
+			equal(t, created.ID, resp.Items[0].ID, "unexpected window")
+		})
+
+		t.Run("read", func(t *testing.T) {
+			rec := do(t, http.MethodGet, "/health/maintenance/"+created.ID, nil)
+
+			mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+
+			var resp health.MaintenanceWindow
+			decodeBody(t, rec.Body, &resp)
+			equal(t, created.Labels, resp.Labels, "unexpected labels")
+		})
+
+		t.Run("delete", func(t *testing.T) {
+			equal(t, http.StatusNoContent, do(t, http.MethodDelete, "/health/maintenance/"+created.ID, nil).Code, "bad status code")
+			equal(t, http.StatusNotFound, do(t, http.MethodGet, "/health/maintenance/"+created.ID, nil).Code, "bad status code")
+			equal(t, http.StatusNotFound, do(t, http.MethodDelete, "/health/maintenance/"+created.ID, nil).Code, "bad status code")
+		})
+
+		t.Run("invalid id", func(t *testing.T) {
+			equal(t, http.StatusUnprocessableEntity, do(t, http.MethodGet, "/health/maintenance/id-1", nil).Code, "bad status code")
+		})
+	})
+
+	t.Run("secrets are redacted", func(t *testing.T) {
+		newCheck := func() health.Check {
+			return health.Check{
+				ID:       "id-1",
+				Endpoint: "http://example.com",
+				Request: &health.Request{
+					Headers: map[string]string{"X-Api-Key": "key", "X-Auth": "s3cr3t", "X-Vault-Auth": "s3cr3t", "Accept": "application/json"},
+					Auth:    &health.Auth{Type: health.AuthBasic, Username: "user", Password: "pass"},
+				},
+			}
+		}
+
+		assertRedacted := func(t *testing.T, c health.Check) {
+			t.Helper()
+
+			mustEqual(t, true, c.Request != nil && c.Request.Auth != nil, "request missing")
+			equal(t, "user", c.Request.Auth.Username, "unexpected username")
+			equal(t, "REDACTED", c.Request.Auth.Password, "password not redacted")
+			equal(t, "REDACTED", c.Request.Headers["X-Api-Key"], "api key header not redacted")
+			equal(t, "REDACTED", c.Request.Headers["X-Auth"], "custom auth header not redacted")
+			equal(t, "REDACTED", c.Request.Headers["X-Vault-Auth"], "custom auth header not redacted")
+			equal(t, "application/json", c.Request.Headers["Accept"], "unexpected accept header")
+		}
+
+		t.Run("read", func(t *testing.T) {
+			check := newCheck()
+			svc := &fakeSVC{
+				readFn: func(id string) (health.Check, error) { return check, nil },
+			}
+
+			rec := httptest.NewRecorder()
+			health.NewHTTPServer(svc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/checks/id-1", nil))
+
+			mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+
+			var resp health.Check
+			decodeBody(t, rec.Body, &resp)
+			assertRedacted(t, resp)
+			equal(t, "pass", check.Request.Auth.Password, "stored check was modified")
+		})
+
+		t.Run("list", func(t *testing.T) {
+			checks := []health.Check{newCheck()}
+			svc := &fakeSVC{
+				listFn: func(page int) (int, int, []health.Check) { return 1, 1, checks },
+			}
+
+			rec := httptest.NewRecorder()
+			health.NewHTTPServer(svc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/checks", nil))
+
+			mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+
+			var resp struct {
+				Items []health.Check `json:"items"`
+			}
+			decodeBody(t, rec.Body, &resp)
+			mustEqual(t, 1, len(resp.Items), "unexpected number of checks")
+			assertRedacted(t, resp.Items[0])
+			equal(t, "pass", checks[0].Request.Auth.Password, "stored check was modified")
+		})
+	})
+
 	t.Run("delete", func(t *testing.T) {
 		t.Run("when endpoint exists should delete it without error", func(t *testing.T) {
 
@@ -142,7 +681,7 @@ func TestHTTPServer(t *testing.T) {
 func mustEqual(t *testing.T, expected, got interface{}, msg string) {
 	t.Helper()
 
-	if expected != got {
+	if !reflect.DeepEqual(expected, got) {
 		t.Fatalf("%s: expected=%#v got=%#v", msg, expected, got)
 	}
 }
@@ -150,7 +689,7 @@ func mustEqual(t *testing.T, expected, got interface{}, msg string) {
 func equal(t *testing.T, expected, got interface{}, msg string) {
 	t.Helper()
 
-	if expected != got {
+	if !reflect.DeepEqual(expected, got) {
 		t.Errorf("%s: expected=%#v got=%#v", msg, expected, got)
 	}
 }
@@ -174,16 +713,29 @@ func encodeBody(t *testing.T, v interface{}) *bytes.Buffer {
 }
 
 type fakeSVC struct {
-	createFn func(endpoint string) (health.Check, error)
-	listFn   func(page int) (int, int, []health.Check)
-	readFn   func(id string) (health.Check, error)
+	createFn  func(check health.Check) (health.Check, error)
+	pauseFn   func(id string) (health.Check, error)
+	resumeFn  func(id string) (health.Check, error)
+	listFn    func(page int) (int, int, []health.Check)
+	readFn    func(id string) (health.Check, error)
+	deleteFn  func(id string) error
+	runFn     func(ctx context.Context, id string) (health.Check, error)
+	resultsFn func(id string, from, to time.Time, limit int) ([]health.Result, error)
+	eventsFn  func(id string, from, to time.Time, limit int) ([]health.Event, error)
+	uptimeFn  func(id string, window time.Duration) (health.Uptime, error)
+	statsFn   func(id string, window time.Duration) (health.LatencyStats, error)
+
+	createMaintenanceFn func(w health.MaintenanceWindow) (health.MaintenanceWindow, error)
+	readMaintenanceFn   func(id string) (health.MaintenanceWindow, error)
+	listMaintenanceFn   func() ([]health.MaintenanceWindow, error)
+	deleteMaintenanceFn func(id string) error
 }
 
-func (f *fakeSVC) Create(endpoint string) (health.Check, error) {
+func (f *fakeSVC) Create(check health.Check) (health.Check, error) {
 	if f.createFn == nil {
 		panic("create not implemented")
 	}
-	return f.createFn(endpoint)
+	return f.createFn(check)
 }
 
 func (f *fakeSVC) List(page int) (int, int, []health.Check) {
@@ -199,3 +751,87 @@ func (f *fakeSVC) Read(id string) (health.Check, error) {
 	}
 	return f.readFn(id)
 }
+
+func (f *fakeSVC) Delete(id string) error {
+	if f.deleteFn == nil {
+		panic("delete not implemented")
+	}
+	return f.deleteFn(id)
+}
+
+func (f *fakeSVC) Run(ctx context.Context, id string) (health.Check, error) {
+	if f.runFn == nil {
+		panic("run not implemented")
+	}
+	return f.runFn(ctx, id)
+}
+
+func (f *fakeSVC) Results(id string, from, to time.Time, limit int) ([]health.Result, error) {
+	if f.resultsFn == nil {
+		panic("results not implemented")
+	}
+	return f.resultsFn(id, from, to, limit)
+}
+
+func (f *fakeSVC) Events(id string, from, to time.Time, limit int) ([]health.Event, error) {
+	if f.eventsFn == nil {
+		panic("events not implemented")
+	}
+	return f.eventsFn(id, from, to, limit)
+}
+
+func (f *fakeSVC) Pause(id string) (health.Check, error) {
+	if f.pauseFn == nil {
+		panic("pause not implemented")
+	}
+	return f.pauseFn(id)
+}
+
+func (f *fakeSVC) Resume(id string) (health.Check, error) {
+	if f.resumeFn == nil {
+		panic("resume not implemented")
+	}
+	return f.resumeFn(id)
+}
+
+func (f *fakeSVC) Uptime(id string, window time.Duration) (health.Uptime, error) {
+	if f.uptimeFn == nil {
+		panic("uptime not implemented")
+	}
+	return f.uptimeFn(id, window)
+}
+
+func (f *fakeSVC) Stats(id string, window time.Duration) (health.LatencyStats, error) {
+	if f.statsFn == nil {
+		panic("stats not implemented")
+	}
+	return f.statsFn(id, window)
+}
+
+func (f *fakeSVC) CreateMaintenance(w health.MaintenanceWindow) (health.MaintenanceWindow, error) {
+	if f.createMaintenanceFn == nil {
+		panic("create maintenance not implemented")
+	}
+	return f.createMaintenanceFn(w)
+}
+
+func (f *fakeSVC) ReadMaintenance(id string) (health.MaintenanceWindow, error) {
+	if f.readMaintenanceFn == nil {
+		panic("read maintenance not implemented")
+	}
+	return f.readMaintenanceFn(id)
+}
+
+func (f *fakeSVC) ListMaintenance() ([]health.MaintenanceWindow, error) {
+	if f.listMaintenanceFn == nil {
+		panic("list maintenance not implemented")
+	}
+	return f.listMaintenanceFn()
+}
+
+func (f *fakeSVC) DeleteMaintenance(id string) error {
+	if f.deleteMaintenanceFn == nil {
+		panic("delete maintenance not implemented")
+	}
+	return f.deleteMaintenanceFn(id)
+}
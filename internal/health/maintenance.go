@@ -0,0 +1,279 @@
+package health
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// MaintenanceWindow silences the checks it targets while it is active, the
+// probes of the checks are recorded, but do not change the state of the checks
+// or fire notifications.
+//
+// A one-off window is active from Start until End. A recurring window is
+// active for Duration every time its Cron schedule fires, bound by Start and
+// End when they are set.
+type MaintenanceWindow struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+
+	// CheckIDs and Labels target the checks of the window, a check is
+	// targeted by its id, or by having every one of the labels.
+	CheckIDs []string          `json:"check_ids,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+
+	Start time.Time `json:"start,omitempty"`
+	End   time.Time `json:"end,omitempty"`
+
+	// Cron is a 5 field cron expression, evaluated in the Timezone, UTC when
+	// not set, of the times the window starts.
+	Cron     string   `json:"cron,omitempty"`
+	Duration Duration `json:"duration,omitempty"`
+	Timezone string   `json:"timezone,omitempty"`
+
+	// Active is whether the window is active at the time it was read.
+	Active bool `json:"active"`
+
+	// schedule and location are the parsed Cron and Timezone of a recurring
+	// window, see compile.
+	schedule *cronSchedule
+	location *time.Location
+}
+
+const maxMaintenanceDuration = 7 * 24 * time.Hour
+
+var (
+	errInvalidMaintenanceTarget   = errors.New("maintenance window must target check ids or labels")
+	errInvalidMaintenanceTime     = errors.New("maintenance window must have a start before its end")
+	errInvalidMaintenanceDuration = errors.New("duration of a recurring maintenance window must be positive and no greater than 7d")
+	errInvalidTimezone            = errors.New("timezone must be an IANA time zone, i.e. Europe/Berlin")
+	errMaintenanceNotFound        = errors.New("maintenance window not found by the provided id")
+)
+
+// validateMaintenanceWindow validates the window, compiling it when it is
+// valid.
+func validateMaintenanceWindow(w *MaintenanceWindow) error {
+	if len(w.CheckIDs) == 0 && len(w.Labels) == 0 {
+		return errInvalidMaintenanceTarget
+	}
+	if err := validateLabels(w.Labels); err != nil {
+		return err
+	}
+
+	if w.Cron == "" {
+		if w.Start.IsZero() || w.End.IsZero() || !w.Start.Before(w.End) {
+			return errInvalidMaintenanceTime
+		}
+		return nil
+	}
+
+	if !w.Start.IsZero() && !w.End.IsZero() && !w.Start.Before(w.End) {
+		return errInvalidMaintenanceTime
+	}
+	if w.Duration <= 0 || time.Duration(w.Duration) > maxMaintenanceDuration {
+		return errInvalidMaintenanceDuration
+	}
+	return w.compile()
+}
+
+// compile parses the cron expression, and loads the timezone, of a recurring
+// window once, rather than every time the window is evaluated.
+func (w *MaintenanceWindow) compile() error {
+	if w.Cron == "" {
+		return nil
+	}
+
+	sched, err := parseCron(w.Cron)
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return errInvalidTimezone
+	}
+	w.schedule, w.location = &sched, loc
+	return nil
+}
+
+// active reports whether the window is active at the time.
+func (w MaintenanceWindow) active(at time.Time) bool {
+	if !w.Start.IsZero() && at.Before(w.Start) {
+		return false
+	}
+	if !w.End.IsZero() && !at.Before(w.End) {
+		return false
+	}
+	if w.Cron == "" {
+		return true
+	}
+
+	if w.schedule == nil {
+		// windows provided by a repository that does not compile them
+		if err := w.compile(); err != nil {
+			return false
+		}
+	}
+
+	duration := time.Duration(w.Duration)
+	fired, ok := w.schedule.lastFire(at.In(w.location), duration)
+	return ok && at.Before(fired.Add(duration))
+}
+
+// targets reports whether the check is targeted by the window.
+func (w MaintenanceWindow) targets(c Check) bool {
+	for _, id := range w.CheckIDs {
+		if id == c.ID {
+			return true
+		}
+	}
+
+	if len(w.Labels) == 0 {
+		return false
+	}
+	for k, v := range w.Labels {
+		if got, ok := c.Labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func newMaintenanceID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+type MaintenanceRepository interface {
+	CreateWindow(w MaintenanceWindow) error
+	ReadWindow(id string) (MaintenanceWindow, error)
+	// ListWindows returns every window, in the order they were created.
+	ListWindows() ([]MaintenanceWindow, error)
+	DeleteWindow(id string) error
+}
+
+type maintenanceRepository struct {
+	// filepath is the JSON file the windows are persisted to, the windows
+	// are kept in memory only when it is empty.
+	filepath string
+
+	mu      sync.Mutex
+	windows []MaintenanceWindow
+}
+
+var _ MaintenanceRepository = (*maintenanceRepository)(nil)
+
+// NewMemoryMaintenanceRepository creates a maintenance repository that keeps
+// the windows in memory.
+func NewMemoryMaintenanceRepository() MaintenanceRepository {
+	return &maintenanceRepository{}
+}
+
+// NewFileMaintenanceRepository creates a maintenance repository that persists
+// the windows to a JSON file.
+func NewFileMaintenanceRepository(path string) (MaintenanceRepository, error) {
+	r := &maintenanceRepository{filepath: path}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+	if len(b) == 0 {
+		return r, nil
+	}
+	if err := json.Unmarshal(b, &r.windows); err != nil {
+		return nil, err
+	}
+	for i := range r.windows {
+		if err := r.windows[i].compile(); err != nil {
+			return nil, fmt.Errorf("maintenance window %s: %s", r.windows[i].ID, err)
+		}
+	}
+	return r, nil
+}
+
+func (r *maintenanceRepository) CreateWindow(w MaintenanceWindow) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	windows := append(r.windows[:len(r.windows):len(r.windows)], w)
+	if err := r.toDisk(windows); err != nil {
+		return err
+	}
+	r.windows = windows
+	return nil
+}
+
+func (r *maintenanceRepository) ReadWindow(id string) (MaintenanceWindow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, w := range r.windows {
+		if w.ID == id {
+			return w, nil
+		}
+	}
+	return MaintenanceWindow{}, errMaintenanceNotFound
+}
+
+func (r *maintenanceRepository) ListWindows() ([]MaintenanceWindow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]MaintenanceWindow{}, r.windows...), nil
+}
+
+func (r *maintenanceRepository) DeleteWindow(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	windows := make([]MaintenanceWindow, 0, len(r.windows))
+	for _, w := range r.windows {
+		if w.ID != id {
+			windows = append(windows, w)
+		}
+	}
+	if len(windows) == len(r.windows) {
+		return errMaintenanceNotFound
+	}
+
+	if err := r.toDisk(windows); err != nil {
+		return err
+	}
+	r.windows = windows
+	return nil
+}
+
+func (r *maintenanceRepository) toDisk(windows []MaintenanceWindow) error {
+	if r.filepath == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(windows)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.filepath, b, 0644)
+}
+
+// activeMaintenance provides the id of the first maintenance window targeting
+// the check that is active at the time, empty when there is none.
+func activeMaintenance(windows []MaintenanceWindow, c Check, at time.Time) string {
+	for _, w := range windows {
+		if w.targets(c) && w.active(at) {
+			return w.ID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,290 @@
+package health_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestMaintenance(t *testing.T) {
+	// a sunday
+	sunday := time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	t.Run("active", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			window   health.MaintenanceWindow
+			now      time.Time
+			expected bool
+		}{
+			{
+				name:   "before one-off window",
+				window: health.MaintenanceWindow{Start: sunday.Add(time.Hour), End: sunday.Add(2 * time.Hour)},
+				now:    sunday.Add(59 * time.Minute),
+			},
+			{
+				name:     "within one-off window",
+				window:   health.MaintenanceWindow{Start: sunday.Add(time.Hour), End: sunday.Add(2 * time.Hour)},
+				now:      sunday.Add(time.Hour),
+				expected: true,
+			},
+			{
+				name:   "end of one-off window",
+				window: health.MaintenanceWindow{Start: sunday.Add(time.Hour), End: sunday.Add(2 * time.Hour)},
+				now:    sunday.Add(2 * time.Hour),
+			},
+			{
+				name:   "before recurring window",
+				window: health.MaintenanceWindow{Cron: "0 2 * * 0", Duration: health.Duration(2 * time.Hour)},
+				now:    sunday.Add(119 * time.Minute),
+			},
+			{
+				name:     "start of recurring window",
+				window:   health.MaintenanceWindow{Cron: "0 2 * * 0", Duration: health.Duration(2 * time.Hour)},
+				now:      sunday.Add(2 * time.Hour),
+				expected: true,
+			},
+			{
+				name:     "within recurring window",
+				window:   health.MaintenanceWindow{Cron: "0 2 * * 0", Duration: health.Duration(2 * time.Hour)},
+				now:      sunday.Add(4*time.Hour - time.Second),
+				expected: true,
+			},
+			{
+				name:   "end of recurring window",
+				window: health.MaintenanceWindow{Cron: "0 2 * * 0", Duration: health.Duration(2 * time.Hour)},
+				now:    sunday.Add(4 * time.Hour),
+			},
+			{
+				name:   "recurring window on another day",
+				window: health.MaintenanceWindow{Cron: "0 2 * * 0", Duration: health.Duration(2 * time.Hour)},
+				now:    sunday.Add(26 * time.Hour),
+			},
+			{
+				name:     "recurring window spanning midnight",
+				window:   health.MaintenanceWindow{Cron: "30 23 * * *", Duration: health.Duration(time.Hour)},
+				now:      sunday.Add(15 * time.Minute),
+				expected: true,
+			},
+			{
+				name:     "sunday as 7",
+				window:   health.MaintenanceWindow{Cron: "0 2 * * 6,7", Duration: health.Duration(time.Hour)},
+				now:      sunday.Add(150 * time.Minute),
+				expected: true,
+			},
+			{
+				name:     "within step",
+				window:   health.MaintenanceWindow{Cron: "*/15 * * * *", Duration: health.Duration(5 * time.Minute)},
+				now:      sunday.Add(19 * time.Minute),
+				expected: true,
+			},
+			{
+				name:   "between steps",
+				window: health.MaintenanceWindow{Cron: "*/15 * * * *", Duration: health.Duration(5 * time.Minute)},
+				now:    sunday.Add(20 * time.Minute),
+			},
+			{
+				name:     "day of month or day of week matches day of month",
+				window:   health.MaintenanceWindow{Cron: "0 2 1 * 1", Duration: health.Duration(time.Hour)},
+				now:      sunday.AddDate(0, 0, -4).Add(150 * time.Minute),
+				expected: true,
+			},
+			{
+				name:     "day of month or day of week matches day of week",
+				window:   health.MaintenanceWindow{Cron: "0 2 1 * 1", Duration: health.Duration(time.Hour)},
+				now:      sunday.AddDate(0, 0, 1).Add(150 * time.Minute),
+				expected: true,
+			},
+			{
+				name:   "day of month or day of week matches neither",
+				window: health.MaintenanceWindow{Cron: "0 2 1 * 1", Duration: health.Duration(time.Hour)},
+				now:    sunday.AddDate(0, 0, 2).Add(150 * time.Minute),
+			},
+			{
+				name:     "recurring window lasting days",
+				window:   health.MaintenanceWindow{Cron: "0 2 * * 1", Duration: health.Duration(7 * 24 * time.Hour)},
+				now:      sunday.Add(90 * time.Minute),
+				expected: true,
+			},
+			{
+				name:   "recurring window in another month",
+				window: health.MaintenanceWindow{Cron: "0 2 * 3 *", Duration: health.Duration(time.Hour)},
+				now:    sunday.Add(150 * time.Minute),
+			},
+			{
+				name:     "recurring window in its timezone",
+				window:   health.MaintenanceWindow{Cron: "0 2 * * *", Duration: health.Duration(time.Hour), Timezone: "America/New_York"},
+				now:      sunday.Add(7*time.Hour + 30*time.Minute),
+				expected: true,
+			},
+			{
+				name:   "recurring window in utc outside its timezone",
+				window: health.MaintenanceWindow{Cron: "0 2 * * *", Duration: health.Duration(time.Hour), Timezone: "America/New_York"},
+				now:    sunday.Add(150 * time.Minute),
+			},
+			{
+				name:   "recurring window past its end",
+				window: health.MaintenanceWindow{Cron: "0 2 * * *", Duration: health.Duration(time.Hour), End: sunday},
+				now:    sunday.Add(150 * time.Minute),
+			},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				svc := health.NewSVC(&fakeRepo{}, health.WithClock(newFakeClock(tt.now)))
+
+				tt.window.CheckIDs = []string{"id-1"}
+				w, err := svc.CreateMaintenance(tt.window)
+				mustNoError(t, err)
+
+				equal(t, tt.expected, w.Active, "unexpected active")
+			}
+
+			t.Run(tt.name, fn)
+		}
+	})
+
+	t.Run("probes during maintenance", func(t *testing.T) {
+		id := strings.Repeat("a", 44)
+
+		newSVC := func(check health.Check, ok bool, opts ...health.SVCOption) (health.SVC, *time.Time) {
+			check.ID = id
+			check.Endpoint = "http://example.com"
+			repo := &fakeRepo{
+				readFn: func(id string) (health.Check, error) {
+					return check, nil
+				},
+				updateFn: func(c health.Check) error {
+					check = c
+					return nil
+				},
+			}
+
+			checked := sunday.Add(150 * time.Minute)
+			prober := &fakeProber{
+				probeFn: func(ctx context.Context, c health.Check) health.Result {
+					return health.Result{CheckID: c.ID, OK: ok, Checked: checked}
+				},
+			}
+			return health.NewSVC(repo, append([]health.SVCOption{health.WithProber(prober)}, opts...)...), &checked
+		}
+
+		weekly := health.MaintenanceWindow{
+			Labels:   map[string]string{"team": "db"},
+			Cron:     "0 2 * * 0",
+			Duration: health.Duration(2 * time.Hour),
+		}
+
+		t.Run("failures are recorded without changing the state", func(t *testing.T) {
+			var notified int
+			notifier := health.NotifierFunc(func(ctx context.Context, c health.Check, e health.Event) error {
+				notified++
+				return nil
+			})
+			history := health.NewMemoryHistoryRepository(10)
+
+			svc, checked := newSVC(
+				health.Check{State: health.StateUp, Labels: map[string]string{"team": "db", "env": "prod"}},
+				false,
+				health.WithNotifier(notifier),
+				health.WithHistory(history),
+			)
+			w, err := svc.CreateMaintenance(weekly)
+			mustNoError(t, err)
+
+			check, err := svc.Run(context.Background(), id)
+			mustNoError(t, err)
+
+			equal(t, health.StateUp, check.State, "unexpected state")
+			equal(t, 0, check.ConsecutiveFailures, "unexpected consecutive failures")
+			equal(t, w.ID, check.Maintenance, "unexpected maintenance")
+			equal(t, 0, notified, "unexpected notifications")
+
+			results, err := history.Results(id, sunday, sunday.Add(24*time.Hour), 0)
+			mustNoError(t, err)
+			mustEqual(t, 1, len(results), "unexpected number of results")
+			equal(t, false, results[0].OK, "unexpected ok")
+			equal(t, w.ID, results[0].Maintenance, "unexpected result maintenance")
+
+			events, err := history.Events(id, sunday, sunday.Add(24*time.Hour), 0)
+			mustNoError(t, err)
+			equal(t, 0, len(events), "unexpected events")
+
+			t.Run("and change it once the window ends", func(t *testing.T) {
+				*checked = sunday.Add(4 * time.Hour)
+
+				check, err := svc.Run(context.Background(), id)
+				mustNoError(t, err)
+
+				equal(t, health.StateDown, check.State, "unexpected state")
+				equal(t, "", check.Maintenance, "unexpected maintenance")
+				equal(t, 1, notified, "unexpected notifications")
+			})
+		})
+
+		t.Run("checks without the labels are not silenced", func(t *testing.T) {
+			svc, _ := newSVC(health.Check{State: health.StateUp, Labels: map[string]string{"team": "web"}}, false)
+			_, err := svc.CreateMaintenance(weekly)
+			mustNoError(t, err)
+
+			check, err := svc.Run(context.Background(), id)
+			mustNoError(t, err)
+
+			equal(t, health.StateDown, check.State, "unexpected state")
+			equal(t, "", check.Maintenance, "unexpected maintenance")
+		})
+
+		t.Run("checks targeted by id are silenced", func(t *testing.T) {
+			svc, _ := newSVC(health.Check{State: health.StateUp}, false)
+			w, err := svc.CreateMaintenance(health.MaintenanceWindow{
+				CheckIDs: []string{id},
+				Start:    sunday,
+				End:      sunday.Add(24 * time.Hour),
+			})
+			mustNoError(t, err)
+
+			check, err := svc.Run(context.Background(), id)
+			mustNoError(t, err)
+
+			equal(t, health.StateUp, check.State, "unexpected state")
+			equal(t, w.ID, check.Maintenance, "unexpected maintenance")
+		})
+	})
+
+	t.Run("file repository persists the windows", func(t *testing.T) {
+		tmpDir, err := ioutil.TempDir("", "")
+		mustNoError(t, err)
+		defer os.RemoveAll(tmpDir)
+
+		path := filepath.Join(tmpDir, "maintenance.json")
+
+		repo, err := health.NewFileMaintenanceRepository(path)
+		mustNoError(t, err)
+
+		svc := health.NewSVC(&fakeRepo{}, health.WithMaintenance(repo))
+		first, err := svc.CreateMaintenance(health.MaintenanceWindow{CheckIDs: []string{"id-1"}, Cron: "0 2 * * 0", Duration: health.Duration(time.Hour)})
+		mustNoError(t, err)
+		second, err := svc.CreateMaintenance(health.MaintenanceWindow{CheckIDs: []string{"id-2"}, Start: sunday, End: sunday.Add(time.Hour)})
+		mustNoError(t, err)
+		mustNoError(t, svc.DeleteMaintenance(first.ID))
+
+		stats, err := os.Stat(path)
+		mustNoError(t, err)
+		equal(t, os.FileMode(0644), stats.Mode().Perm(), "unexpected permissions")
+
+		repo, err = health.NewFileMaintenanceRepository(path)
+		mustNoError(t, err)
+
+		windows, err := repo.ListWindows()
+		mustNoError(t, err)
+		mustEqual(t, 1, len(windows), "unexpected number of windows")
+		equal(t, second.ID, windows[0].ID, "unexpected window")
+		equal(t, true, sunday.Equal(windows[0].Start), "unexpected start")
+	})
+}
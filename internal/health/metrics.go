@@ -0,0 +1,142 @@
+package health
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type probeMetrics struct {
+	probes    *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+}
+
+func newProbeMetrics(reg prometheus.Registerer, repo Repository) *probeMetrics {
+	m := &probeMetrics{
+		probes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "health_probes_total",
+			Help: "Total number of probes of a check by result.",
+		}, []string{"id", "endpoint", "result"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "health_probe_duration_seconds",
+			Help:    "Latency of the probes of a check.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"id", "endpoint"}),
+	}
+	reg.MustRegister(m.probes, m.durations, newCheckCollector(repo))
+	return m
+}
+
+func (m *probeMetrics) observe(c Check, res Result) {
+	if m == nil {
+		return
+	}
+
+	result := "success"
+	if !res.OK {
+		result = "failure"
+	}
+	m.probes.WithLabelValues(c.ID, c.Endpoint, result).Inc()
+	if res.Error == "" {
+		m.durations.WithLabelValues(c.ID, c.Endpoint).Observe(time.Duration(res.Duration).Seconds())
+	}
+}
+
+func (m *probeMetrics) delete(id string) {
+	if m == nil {
+		return
+	}
+
+	m.probes.DeletePartialMatch(prometheus.Labels{"id": id})
+	m.durations.DeletePartialMatch(prometheus.Labels{"id": id})
+}
+
+var checkLabels = []string{"id", "endpoint"}
+
+var (
+	checkUpDesc         = prometheus.NewDesc("health_check_up", "Whether the latest probe of the check succeeded.", checkLabels, nil)
+	checkFlappingDesc   = prometheus.NewDesc("health_check_flapping", "Whether the check is flapping.", checkLabels, nil)
+	checkCodeDesc       = prometheus.NewDesc("health_check_status_code", "Status code returned by the latest probe of the check.", checkLabels, nil)
+	checkLatencyDesc    = prometheus.NewDesc("health_check_latency_seconds", "Latency of the latest probe of the check.", checkLabels, nil)
+	checkCheckedDesc    = prometheus.NewDesc("health_check_last_probe_timestamp_seconds", "Unix time of the latest probe of the check.", checkLabels, nil)
+	checkCertExpiryDesc = prometheus.NewDesc("health_check_tls_cert_expiry_timestamp_seconds", "Unix time the leaf certificate of the check expires.", checkLabels, nil)
+)
+
+// checkCollector provides the gauges describing the latest probe of each
+// check, read from the repository at scrape time.
+type checkCollector struct {
+	repo Repository
+}
+
+var _ prometheus.Collector = (*checkCollector)(nil)
+
+func newCheckCollector(repo Repository) *checkCollector {
+	return &checkCollector{repo: repo}
+}
+
+func (c *checkCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- checkUpDesc
+	ch <- checkFlappingDesc
+	ch <- checkCodeDesc
+	ch <- checkLatencyDesc
+	ch <- checkCheckedDesc
+	ch <- checkCertExpiryDesc
+}
+
+func (c *checkCollector) Collect(ch chan<- prometheus.Metric) {
+	_, checks := c.repo.List(0, -1)
+	for _, check := range checks {
+		// paused checks are not probed, so have nothing to describe
+		if check.Checked == 0 || check.State == StatePaused {
+			continue
+		}
+
+		gauge := func(desc *prometheus.Desc, v float64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, check.ID, check.Endpoint)
+		}
+
+		var isUp float64
+		if state := settledState(check); state == StateUp || state == StateDegraded {
+			isUp = 1
+		}
+		gauge(checkUpDesc, isUp)
+
+		var isFlapping float64
+		if check.State == StateFlapping {
+			isFlapping = 1
+		}
+		gauge(checkFlappingDesc, isFlapping)
+		gauge(checkCodeDesc, float64(check.Code))
+		gauge(checkCheckedDesc, float64(check.Checked))
+		if d, err := time.ParseDuration(check.Duration); err == nil {
+			gauge(checkLatencyDesc, d.Seconds())
+		}
+		if check.TLS != nil {
+			gauge(checkCertExpiryDesc, float64(check.TLS.Expiry.Unix()))
+		}
+	}
+}
+
+var (
+	poolWorkersDesc  = prometheus.NewDesc("health_probe_pool_workers", "Max number of probes in flight.", nil, nil)
+	poolQueuedDesc   = prometheus.NewDesc("health_probe_pool_queued", "Number of probes waiting for a worker.", nil, nil)
+	poolInFlightDesc = prometheus.NewDesc("health_probe_pool_in_flight", "Number of probes in flight.", nil, nil)
+	poolDroppedDesc  = prometheus.NewDesc("health_probe_pool_dropped_total", "Total number of probes dropped as the queue was full.", nil, nil)
+)
+
+var _ prometheus.Collector = (*Pool)(nil)
+
+func (p *Pool) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolWorkersDesc
+	ch <- poolQueuedDesc
+	ch <- poolInFlightDesc
+	ch <- poolDroppedDesc
+}
+
+func (p *Pool) Collect(ch chan<- prometheus.Metric) {
+	stats := p.Stats()
+	ch <- prometheus.MustNewConstMetric(poolWorkersDesc, prometheus.GaugeValue, float64(stats.Workers))
+	ch <- prometheus.MustNewConstMetric(poolQueuedDesc, prometheus.GaugeValue, float64(stats.Queued))
+	ch <- prometheus.MustNewConstMetric(poolInFlightDesc, prometheus.GaugeValue, float64(stats.InFlight))
+	ch <- prometheus.MustNewConstMetric(poolDroppedDesc, prometheus.CounterValue, float64(stats.Dropped))
+}
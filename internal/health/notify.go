@@ -0,0 +1,38 @@
+package health
+
+import (
+	"context"
+	"log"
+)
+
+// Notifier is notified of every state transition of a check resulting from a
+// probe. A flapping check only notifies when it starts and stops flapping.
+type Notifier interface {
+	Notify(ctx context.Context, c Check, e Event) error
+}
+
+type NotifierFunc func(ctx context.Context, c Check, e Event) error
+
+func (f NotifierFunc) Notify(ctx context.Context, c Check, e Event) error {
+	return f(ctx, c, e)
+}
+
+type logNotifier struct {
+	logger *log.Logger
+}
+
+// NewLogNotifier creates a notifier that logs the transitions with the logger,
+// or the standard logger when it is nil.
+func NewLogNotifier(logger *log.Logger) Notifier {
+	return &logNotifier{logger: logger}
+}
+
+func (n *logNotifier) Notify(ctx context.Context, c Check, e Event) error {
+	format, args := "check %s (%s) is %s, was %s: %s", []interface{}{c.ID, c.Endpoint, e.To, e.From, e.Reason}
+	if n.logger == nil {
+		log.Printf(format, args...)
+		return nil
+	}
+	n.logger.Printf(format, args...)
+	return nil
+}
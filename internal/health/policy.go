@@ -0,0 +1,103 @@
+package health
+
+import (
+	"errors"
+	"time"
+)
+
+// Clock provides the current time and the waits between retries, allowing the
+// retry and threshold policy of the service to be driven by tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+const (
+	maxRetries          = 10
+	maxThreshold        = 100
+	defaultRetryBackoff = time.Second
+)
+
+var (
+	errInvalidRetries      = errors.New("retries must be between 0 and 10")
+	errInvalidRetryBackoff = errors.New("retry_backoff must be positive and less than the interval")
+	errInvalidThreshold    = errors.New("failure_threshold and success_threshold must be between 0 and 100")
+)
+
+func validatePolicy(c Check, interval time.Duration) error {
+	if c.Retries < 0 || c.Retries > maxRetries {
+		return errInvalidRetries
+	}
+	if c.RetryBackoff < 0 || time.Duration(c.RetryBackoff) >= interval {
+		return errInvalidRetryBackoff
+	}
+	if c.FailureThreshold < 0 || c.FailureThreshold > maxThreshold ||
+		c.SuccessThreshold < 0 || c.SuccessThreshold > maxThreshold {
+		return errInvalidThreshold
+	}
+	return nil
+}
+
+// retryBackoff provides the wait before the retry following the attempt,
+// doubling with every attempt up to the interval of the check.
+func retryBackoff(c Check, attempt int) time.Duration {
+	backoff := time.Duration(c.RetryBackoff)
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	for i := 0; i < attempt && backoff < checkInterval(c); i++ {
+		backoff *= 2
+	}
+	if interval := checkInterval(c); backoff > interval {
+		return interval
+	}
+	return backoff
+}
+
+func failureThreshold(c Check) int {
+	if c.FailureThreshold <= 0 {
+		return 1
+	}
+	return c.FailureThreshold
+}
+
+func successThreshold(c Check) int {
+	if c.SuccessThreshold <= 0 {
+		return 1
+	}
+	return c.SuccessThreshold
+}
+
+// applyResult advances the consecutive failures and successes of the check
+// with the result. The status of the check only changes once the failure or
+// success threshold is reached, so a transient failure of an up check leaves
+// it up.
+func applyResult(c *Check, res Result) {
+	if res.OK {
+		c.ConsecutiveSuccesses++
+		c.ConsecutiveFailures = 0
+		if c.State == StateUp || c.State == StateDegraded || c.ConsecutiveSuccesses >= successThreshold(*c) {
+			c.State = StateUp
+		}
+		return
+	}
+
+	c.ConsecutiveFailures++
+	c.ConsecutiveSuccesses = 0
+	if c.ConsecutiveFailures >= failureThreshold(*c) {
+		c.State = StateDown
+		if res.Unknown {
+			c.State = StateUnknown
+		}
+	}
+}
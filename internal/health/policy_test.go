@@ -0,0 +1,238 @@
+package health_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestPolicy(t *testing.T) {
+	id := strings.Repeat("a", 44)
+
+	// newRepo persists the updates of the check so its state carries over
+	// from one run to the next.
+	newRepo := func(check health.Check) *fakeRepo {
+		var mu sync.Mutex
+		check.ID = id
+		return &fakeRepo{
+			readFn: func(id string) (health.Check, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				return check, nil
+			},
+			updateFn: func(c health.Check) error {
+				mu.Lock()
+				defer mu.Unlock()
+				check = c
+				return nil
+			},
+		}
+	}
+
+	// newProber provides the results in order, repeating the last one.
+	newProber := func(results ...bool) (*fakeProber, *int) {
+		var probes int
+		return &fakeProber{
+			probeFn: func(ctx context.Context, c health.Check) health.Result {
+				ok := results[len(results)-1]
+				if probes < len(results) {
+					ok = results[probes]
+				}
+				probes++
+				return health.Result{CheckID: c.ID, OK: ok, Checked: time.Unix(1500000000, 0)}
+			},
+		}, &probes
+	}
+
+	t.Run("retries", func(t *testing.T) {
+		tests := []struct {
+			name            string
+			check           health.Check
+			results         []bool
+			expectedState   health.State
+			expectedProbes  int
+			expectedBackoff []time.Duration
+		}{
+			{
+				name:           "success is not retried",
+				check:          health.Check{Retries: 3},
+				results:        []bool{true},
+				expectedState:  health.StateUp,
+				expectedProbes: 1,
+			},
+			{
+				name:            "transient failure is retried with a backoff",
+				check:           health.Check{Retries: 3, RetryBackoff: health.Duration(time.Second)},
+				results:         []bool{false, false, true},
+				expectedState:   health.StateUp,
+				expectedProbes:  3,
+				expectedBackoff: []time.Duration{time.Second, 2 * time.Second},
+			},
+			{
+				name:            "retries are bound by the interval",
+				check:           health.Check{Retries: 3, RetryBackoff: health.Duration(3 * time.Second), Interval: health.Duration(10 * time.Second), Timeout: health.Duration(time.Second)},
+				results:         []bool{false},
+				expectedState:   health.StateDown,
+				expectedProbes:  3,
+				expectedBackoff: []time.Duration{3 * time.Second, 6 * time.Second},
+			},
+			{
+				name:           "retries that cannot time out within the interval are skipped",
+				check:          health.Check{Retries: 3, Interval: health.Duration(10 * time.Second), Timeout: health.Duration(10 * time.Second)},
+				results:        []bool{false},
+				expectedState:  health.StateDown,
+				expectedProbes: 1,
+			},
+			{
+				name:           "failure without retries",
+				check:          health.Check{},
+				results:        []bool{false, true},
+				expectedState:  health.StateDown,
+				expectedProbes: 1,
+			},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				prober, probes := newProber(tt.results...)
+				clock := newFakeClock(time.Unix(1500000000, 0))
+				svc := health.NewSVC(newRepo(tt.check), health.WithProber(prober), health.WithClock(clock))
+
+				check, err := svc.Run(context.Background(), id)
+				mustNoError(t, err)
+
+				equal(t, tt.expectedState, check.State, "unexpected status")
+				equal(t, tt.expectedProbes, *probes, "unexpected number of probes")
+				mustEqual(t, len(tt.expectedBackoff), len(clock.waits), "unexpected number of waits")
+				for i, expected := range tt.expectedBackoff {
+					equal(t, expected, clock.waits[i], "unexpected backoff")
+				}
+			}
+
+			t.Run(tt.name, fn)
+		}
+
+		t.Run("canceled while backing off", func(t *testing.T) {
+			prober, probes := newProber(false)
+			svc := health.NewSVC(
+				newRepo(health.Check{Retries: 3, RetryBackoff: health.Duration(time.Minute), Interval: health.Duration(5 * time.Minute)}),
+				health.WithProber(prober),
+			)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			_, err := svc.Run(ctx, id)
+			equal(t, context.DeadlineExceeded, err, "unexpected error")
+			equal(t, 1, *probes, "unexpected number of probes")
+		})
+	})
+
+	t.Run("thresholds", func(t *testing.T) {
+		tests := []struct {
+			name           string
+			check          health.Check
+			results        []bool
+			expectedStates []health.State
+		}{
+			{
+				name:           "default thresholds follow every result",
+				check:          health.Check{State: health.StatePending},
+				results:        []bool{true, false, true},
+				expectedStates: []health.State{health.StateUp, health.StateDown, health.StateUp},
+			},
+			{
+				name:    "down after consecutive failures",
+				check:   health.Check{State: health.StateUp, FailureThreshold: 3},
+				results: []bool{false, false, true, false, false, false, false},
+				expectedStates: []health.State{
+					health.StateUp, health.StateUp, health.StateUp,
+					health.StateUp, health.StateUp, health.StateDown, health.StateDown,
+				},
+			},
+			{
+				name:    "up after consecutive successes",
+				check:   health.Check{State: health.StateDown, SuccessThreshold: 2},
+				results: []bool{true, false, true, true, true},
+				expectedStates: []health.State{
+					health.StateDown, health.StateDown, health.StateDown, health.StateUp, health.StateUp,
+				},
+			},
+			{
+				name:           "created check waits for a threshold",
+				check:          health.Check{State: health.StatePending, FailureThreshold: 2, SuccessThreshold: 2},
+				results:        []bool{false, true, false, false},
+				expectedStates: []health.State{health.StatePending, health.StatePending, health.StatePending, health.StateDown},
+			},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				prober, _ := newProber(tt.results...)
+				svc := health.NewSVC(newRepo(tt.check), health.WithProber(prober), health.WithClock(newFakeClock(time.Unix(1500000000, 0))))
+
+				for i, expected := range tt.expectedStates {
+					check, err := svc.Run(context.Background(), id)
+					mustNoError(t, err)
+
+					equal(t, expected, check.State, "unexpected status after probe "+strconv.Itoa(i+1))
+				}
+			}
+
+			t.Run(tt.name, fn)
+		}
+
+		t.Run("consecutive results are recorded on the check", func(t *testing.T) {
+			prober, _ := newProber(false, false, true)
+			svc := health.NewSVC(newRepo(health.Check{State: health.StateUp, FailureThreshold: 5}), health.WithProber(prober))
+
+			var check health.Check
+			for i := 0; i < 2; i++ {
+				var err error
+				check, err = svc.Run(context.Background(), id)
+				mustNoError(t, err)
+			}
+			equal(t, 2, check.ConsecutiveFailures, "unexpected consecutive failures")
+			equal(t, 0, check.ConsecutiveSuccesses, "unexpected consecutive successes")
+
+			check, err := svc.Run(context.Background(), id)
+			mustNoError(t, err)
+			equal(t, 0, check.ConsecutiveFailures, "unexpected consecutive failures")
+			equal(t, 1, check.ConsecutiveSuccesses, "unexpected consecutive successes")
+		})
+	})
+}
+
+// fakeClock fires every wait immediately, recording the durations waited.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.waits = append(f.waits, d)
+	f.now = f.now.Add(d)
+
+	ch := make(chan time.Time, 1)
+	ch <- f.now
+	return ch
+}
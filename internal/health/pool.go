@@ -0,0 +1,173 @@
+package health
+
+import (
+	"errors"
+	"sync"
+)
+
+type PoolStats struct {
+	Workers    int    `json:"workers"`
+	MaxPerHost int    `json:"max_per_host"`
+	QueueSize  int    `json:"queue_size"`
+	Queued     int    `json:"queued"`
+	InFlight   int    `json:"in_flight"`
+	Dropped    uint64 `json:"dropped"`
+}
+
+// Pool executes jobs with a fixed number of workers while limiting the
+// number of jobs in flight against any single host.
+type Pool struct {
+	workers    int
+	maxPerHost int
+	queueSize  int
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    []poolJob
+	pending  map[string]bool
+	hosts    map[string]int
+	inFlight int
+	dropped  uint64
+	closed   bool
+
+	wg sync.WaitGroup
+}
+
+type poolJob struct {
+	key  string
+	host string
+	fn   func()
+}
+
+func NewPool(workers, maxPerHost, queueSize int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxPerHost < 1 {
+		maxPerHost = workers
+	}
+
+	p := &Pool{
+		workers:    workers,
+		maxPerHost: maxPerHost,
+		queueSize:  queueSize,
+		pending:    make(map[string]bool),
+		hosts:      make(map[string]int),
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+var (
+	errJobPending = errors.New("job with the key is already queued or in flight")
+	errQueueFull  = errors.New("pool queue is full")
+	errPoolClosed = errors.New("pool is closed")
+)
+
+// Submit queues the job for execution. Jobs sharing a key with a job that
+// is already queued or in flight are rejected with errJobPending. Jobs
+// submitted while the queue is full are dropped with errQueueFull, and
+// counted as such, while the pool being closed rejects them with
+// errPoolClosed.
+func (p *Pool) Submit(key, host string, fn func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return errPoolClosed
+	}
+	if p.pending[key] {
+		return errJobPending
+	}
+	if len(p.queue) >= p.queueSize {
+		p.dropped++
+		return errQueueFull
+	}
+
+	p.pending[key] = true
+	p.queue = append(p.queue, poolJob{key: key, host: host, fn: fn})
+	p.cond.Signal()
+	return nil
+}
+
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return PoolStats{
+		Workers:    p.workers,
+		MaxPerHost: p.maxPerHost,
+		QueueSize:  p.queueSize,
+		Queued:     len(p.queue),
+		InFlight:   p.inFlight,
+		Dropped:    p.dropped,
+	}
+}
+
+// Close discards the queued jobs and waits for the jobs in flight to finish.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	for _, j := range p.queue {
+		delete(p.pending, j.key)
+	}
+	p.queue = nil
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for {
+		job, ok := p.next()
+		if !ok {
+			return
+		}
+
+		job.fn()
+
+		p.mu.Lock()
+		p.inFlight--
+		p.hosts[job.host]--
+		if p.hosts[job.host] == 0 {
+			delete(p.hosts, job.host)
+		}
+		delete(p.pending, job.key)
+		// a host slot has been freed up, which may unblock queued jobs
+		// that any of the idle workers can pick up.
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}
+}
+
+func (p *Pool) next() (poolJob, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		if p.closed {
+			return poolJob{}, false
+		}
+
+		for i, job := range p.queue {
+			if p.hosts[job.host] >= p.maxPerHost {
+				continue
+			}
+
+			p.queue = append(p.queue[:i], p.queue[i+1:]...)
+			p.inFlight++
+			p.hosts[job.host]++
+			return job, true
+		}
+
+		p.cond.Wait()
+	}
+}
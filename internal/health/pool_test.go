@@ -0,0 +1,163 @@
+package health_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestPool(t *testing.T) {
+	t.Run("limits the number of jobs in flight", func(t *testing.T) {
+		tests := []struct {
+			name          string
+			workers       int
+			maxPerHost    int
+			hosts         []string
+			expectedLimit int
+		}{
+			{name: "global limit", workers: 3, maxPerHost: 10, hosts: []string{"a", "b", "c", "d"}, expectedLimit: 3},
+			{name: "per host limit", workers: 10, maxPerHost: 2, hosts: []string{"a"}, expectedLimit: 2},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				pool := health.NewPool(tt.workers, tt.maxPerHost, 100)
+
+				var (
+					mu                sync.Mutex
+					inFlight, maxSeen int
+					wg                sync.WaitGroup
+				)
+				for i := 0; i < 20; i++ {
+					wg.Add(1)
+					host := tt.hosts[i%len(tt.hosts)]
+					err := pool.Submit(string(rune('a'+i)), host, func() {
+						defer wg.Done()
+
+						mu.Lock()
+						inFlight++
+						if inFlight > maxSeen {
+							maxSeen = inFlight
+						}
+						mu.Unlock()
+
+						time.Sleep(5 * time.Millisecond)
+
+						mu.Lock()
+						inFlight--
+						mu.Unlock()
+					})
+					mustNoError(t, err)
+				}
+				wg.Wait()
+				pool.Close()
+
+				equal(t, tt.expectedLimit, maxSeen, "unexpected max jobs in flight")
+			}
+
+			t.Run(tt.name, fn)
+		}
+	})
+
+	t.Run("saturated host does not block other hosts", func(t *testing.T) {
+		pool := health.NewPool(2, 1, 100)
+		defer pool.Close()
+
+		block := make(chan struct{})
+		defer close(block)
+		pool.Submit("a-1", "a", func() { <-block })
+		pool.Submit("a-2", "a", func() { <-block })
+
+		done := make(chan struct{})
+		pool.Submit("b-1", "b", func() { close(done) })
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("job for host b was never executed")
+		}
+	})
+
+	t.Run("rejects jobs with a key already queued or in flight", func(t *testing.T) {
+		pool := health.NewPool(1, 1, 100)
+		defer pool.Close()
+
+		block := make(chan struct{})
+		started := make(chan struct{})
+		mustNoError(t, pool.Submit("key", "host", func() { close(started); <-block }))
+		<-started
+
+		mustError(t, pool.Submit("key", "host", func() {}))
+		equal(t, uint64(0), pool.Stats().Dropped, "duplicate job counted as dropped")
+
+		close(block)
+	})
+
+	t.Run("reports queue depth, in flight and dropped jobs", func(t *testing.T) {
+		pool := health.NewPool(1, 1, 2)
+		defer pool.Close()
+
+		block := make(chan struct{})
+		defer close(block)
+		started := make(chan struct{})
+		pool.Submit("1", "host", func() { close(started); <-block })
+		<-started
+		pool.Submit("2", "host", func() {})
+		pool.Submit("3", "host", func() {})
+		mustError(t, pool.Submit("4", "host", func() {}))
+
+		stats := pool.Stats()
+		equal(t, 1, stats.Workers, "unexpected workers")
+		equal(t, 1, stats.MaxPerHost, "unexpected max per host")
+		equal(t, 2, stats.QueueSize, "unexpected queue size")
+		equal(t, 2, stats.Queued, "unexpected queued")
+		equal(t, 1, stats.InFlight, "unexpected in flight")
+		equal(t, uint64(1), stats.Dropped, "unexpected dropped")
+	})
+
+	t.Run("collects the stats as metrics", func(t *testing.T) {
+		pool := health.NewPool(1, 1, 1)
+		defer pool.Close()
+
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(pool)
+
+		block := make(chan struct{})
+		defer close(block)
+		started := make(chan struct{})
+		pool.Submit("1", "host", func() { close(started); <-block })
+		<-started
+		pool.Submit("2", "host", func() {})
+		mustError(t, pool.Submit("3", "host", func() {}))
+
+		expected := `
+# HELP health_probe_pool_dropped_total Total number of probes dropped as the queue was full.
+# TYPE health_probe_pool_dropped_total counter
+health_probe_pool_dropped_total 1
+# HELP health_probe_pool_in_flight Number of probes in flight.
+# TYPE health_probe_pool_in_flight gauge
+health_probe_pool_in_flight 1
+# HELP health_probe_pool_queued Number of probes waiting for a worker.
+# TYPE health_probe_pool_queued gauge
+health_probe_pool_queued 1
+# HELP health_probe_pool_workers Max number of probes in flight.
+# TYPE health_probe_pool_workers gauge
+health_probe_pool_workers 1
+`
+		mustNoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected)))
+	})
+
+	t.Run("rejects jobs once closed", func(t *testing.T) {
+		pool := health.NewPool(1, 1, 2)
+		pool.Close()
+
+		mustError(t, pool.Submit("key", "host", func() {}))
+		equal(t, uint64(0), pool.Stats().Dropped, "unexpected dropped")
+	})
+}
@@ -0,0 +1,129 @@
+package health
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Result struct {
+	CheckID  string    `json:"check_id"`
+	OK       bool      `json:"ok"`
+	Code     int32     `json:"code"`
+	Checked  time.Time `json:"checked"`
+	Duration Duration  `json:"duration"`
+	// Error describes why the probe failed to get a response.
+	Error string `json:"error,omitempty"`
+	// Assertion names the expectation the response failed, with Failure
+	// describing how it failed.
+	Assertion string `json:"assertion,omitempty"`
+	Failure   string `json:"failure,omitempty"`
+	// Unknown is set when the endpoint responded without knowing whether it
+	// is healthy, i.e. a gRPC health check with an UNKNOWN status.
+	Unknown bool `json:"unknown,omitempty"`
+	// Maintenance is the id of the maintenance window the probe fell in.
+	Maintenance string `json:"maintenance,omitempty"`
+
+	TLS *TLSInfo `json:"tls,omitempty"`
+}
+
+type Prober interface {
+	Probe(ctx context.Context, c Check) Result
+}
+
+// NewProber provides a prober for every type of check, with http checks
+// probed using the client, and the secret refs of checks resolved with the
+// secrets.
+func NewProber(client *http.Client, secrets *Secrets) Prober {
+	return multiProber{
+		TypeHTTP: NewHTTPProber(client, secrets),
+		TypeTCP:  NewTCPProber(),
+		TypeDNS:  NewDNSProber(),
+		TypeGRPC: NewGRPCProber(),
+
+		TypeWebSocket: NewWebSocketProber(secrets),
+	}
+}
+
+// multiProber dispatches the probe of a check to the prober of its type.
+type multiProber map[string]Prober
+
+func (m multiProber) Probe(ctx context.Context, c Check) Result {
+	typ := c.Type
+	if typ == "" {
+		typ = TypeHTTP
+	}
+
+	p, ok := m[typ]
+	if !ok {
+		return Result{
+			CheckID: c.ID,
+			Checked: time.Now(),
+			Error:   "no prober for checks of type " + strconv.Quote(typ),
+		}
+	}
+	return p.Probe(ctx, c)
+}
+
+// maxBodySize limits how much of the response body is read to assert on.
+const maxBodySize = 1 << 20
+
+type httpProber struct {
+	client  *http.Client
+	secrets *Secrets
+}
+
+var _ Prober = (*httpProber)(nil)
+
+func NewHTTPProber(client *http.Client, secrets *Secrets) Prober {
+	if client == nil {
+		// the probe timeout is governed by the context provided to Probe
+		client = &http.Client{}
+	}
+	return &httpProber{
+		client:  client,
+		secrets: secrets,
+	}
+}
+
+func (p *httpProber) Probe(ctx context.Context, c Check) Result {
+	res := Result{
+		CheckID: c.ID,
+		Checked: time.Now(),
+	}
+
+	req, err := http.NewRequest(c.Request.method(), c.Endpoint, strings.NewReader(c.Request.body()))
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if err := c.Request.apply(req, p.secrets); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		res.Duration = Duration(time.Since(res.Checked))
+		res.Error = err.Error()
+		return res
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	res.Duration = Duration(time.Since(res.Checked))
+	res.Code = int32(resp.StatusCode)
+	res.TLS = newTLSInfo(resp.TLS, time.Now())
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	res.Assertion, res.Failure = c.Expect.evaluate(resp.StatusCode, resp.Header, body)
+	res.OK = res.Assertion == ""
+	return res
+}
@@ -0,0 +1,182 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultModules provides the modules available to the probe handler when
+// none are configured.
+func DefaultModules() map[string]Check {
+	return map[string]Check{
+		"http_2xx":    {Type: TypeHTTP, Timeout: Duration(defaultTimeout)},
+		"tcp_connect": {Type: TypeTCP, Timeout: Duration(defaultTimeout)},
+		"dns":         {Type: TypeDNS, Timeout: Duration(defaultTimeout)},
+		"grpc":        {Type: TypeGRPC, Timeout: Duration(defaultTimeout)},
+		"websocket":   {Type: TypeWebSocket, Timeout: Duration(defaultTimeout)},
+	}
+}
+
+// ValidateModules validates the modules as the checks they are the config of
+// would be, other than the target provided when probed, such that a module
+// that cannot be probed is rejected at startup, rather than failing every
+// probe using it.
+func ValidateModules(modules map[string]Check, secrets *Secrets) error {
+	for name, m := range modules {
+		if err := validateModule(m, secrets); err != nil {
+			return fmt.Errorf("module %q: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// errModuleCredentials is returned for a module carrying credentials, as the
+// target a module is probed against is chosen by whoever can reach the probe
+// handler, who could otherwise have the credentials sent to a host of theirs.
+var errModuleCredentials = errors.New("module must not carry auth, secret_headers, or headers other than Accept, Content-Type, User-Agent and the like")
+
+func validateModule(m Check, secrets *Secrets) error {
+	if r := m.Request; r != nil && (r.Auth != nil || len(r.SecretHeaders) > 0 || r.hasSecrets()) {
+		return errModuleCredentials
+	}
+
+	switch m.Type {
+	case "", TypeHTTP, TypeTCP, TypeGRPC, TypeWebSocket:
+	case TypeDNS:
+		if err := validateDNSQuery(m.DNS); err != nil {
+			return err
+		}
+	default:
+		return errInvalidType
+	}
+
+	// modules are probed when scraped rather than on an interval, the
+	// timeout is only bound by the interval when one is set
+	if m.Interval == 0 && m.Timeout > Duration(defaultInterval) {
+		m.Interval = m.Timeout
+	}
+	_, _, _, err := validateConfig(m, secrets)
+	return err
+}
+
+// ProbeHandler performs a single probe of the target provided by the request,
+// using the configuration of the requested module, and responds with the
+// outcome as Prometheus metrics. This mimics the blackbox exporter, allowing
+// Prometheus to drive the probes via its relabelling config.
+type ProbeHandler struct {
+	prober  Prober
+	modules map[string]Check
+}
+
+func NewProbeHandler(prober Prober, modules map[string]Check) *ProbeHandler {
+	return &ProbeHandler{
+		prober:  prober,
+		modules: modules,
+	}
+}
+
+func (h *ProbeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	moduleName := params.Get("module")
+	if moduleName == "" {
+		moduleName = "http_2xx"
+	}
+	check, ok := h.modules[moduleName]
+	if !ok {
+		http.Error(w, "unknown module "+strconv.Quote(moduleName), http.StatusBadRequest)
+		return
+	}
+
+	if (check.Type == "" || check.Type == TypeHTTP) && !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	check.Endpoint = target
+
+	typ, endpoint, err := validateEndpoint(check)
+	if err != nil {
+		http.Error(w, "invalid target: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	check.Type, check.Endpoint = typ, endpoint
+
+	ctx, cancel := context.WithTimeout(r.Context(), probeTimeout(r, check))
+	defer cancel()
+
+	res := h.prober.Probe(ctx, check)
+
+	// a registry per probe, so the metrics describe this probe alone
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(probeCollector(check, res)...)
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probeTimeout bounds the timeout of the module by the scrape timeout of
+// Prometheus, leaving a little headroom for the response to be written.
+func probeTimeout(r *http.Request, c Check) time.Duration {
+	timeout := checkTimeout(c)
+
+	v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if v == "" {
+		return timeout
+	}
+	seconds, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return timeout
+	}
+
+	scrapeTimeout := time.Duration(seconds*float64(time.Second)) - 500*time.Millisecond
+	if scrapeTimeout > 0 && scrapeTimeout < timeout {
+		return scrapeTimeout
+	}
+	return timeout
+}
+
+func probeCollector(c Check, res Result) []prometheus.Collector {
+	gauge := func(name, help string, v float64) prometheus.Collector {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+		g.Set(v)
+		return g
+	}
+
+	var success float64
+	if res.OK {
+		success = 1
+	}
+
+	collectors := []prometheus.Collector{
+		gauge("probe_success", "Displays whether or not the probe was a success", success),
+		gauge("probe_duration_seconds", "Returns how long the probe took to complete in seconds", time.Duration(res.Duration).Seconds()),
+	}
+	if c.Type == TypeHTTP {
+		collectors = append(collectors, gauge("probe_http_status_code", "Response HTTP status code", float64(res.Code)))
+	}
+	if res.TLS != nil {
+		collectors = append(collectors, gauge("probe_ssl_earliest_cert_expiry", "Returns earliest SSL cert expiry in unixtime", float64(earliestExpiry(res.TLS).Unix())))
+	}
+	return collectors
+}
+
+func earliestExpiry(info *TLSInfo) time.Time {
+	earliest := info.Expiry
+	for _, cert := range info.Chain {
+		if cert.Expiry.Before(earliest) {
+			earliest = cert.Expiry
+		}
+	}
+	return earliest
+}
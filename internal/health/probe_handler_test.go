@@ -0,0 +1,163 @@
+package health_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestProbeHandler(t *testing.T) {
+	t.Run("probes the target and responds with metrics", func(t *testing.T) {
+		var probed health.Check
+		prober := &fakeProber{
+			probeFn: func(ctx context.Context, c health.Check) health.Result {
+				probed = c
+				return health.Result{OK: true, Code: 204, Duration: health.Duration(250 * time.Millisecond)}
+			},
+		}
+		modules := map[string]health.Check{
+			"http_slow": {Timeout: health.Duration(30 * time.Second)},
+		}
+
+		h := health.NewProbeHandler(prober, modules)
+
+		req := httptest.NewRequest(http.MethodGet, "/probe?target=example.com:8080/healthz&module=http_slow", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+		equal(t, "http://example.com:8080/healthz", probed.Endpoint, "unexpected target")
+		equal(t, health.Duration(30*time.Second), probed.Timeout, "module config not used")
+
+		for _, expected := range []string{
+			"probe_success 1\n",
+			"probe_duration_seconds 0.25\n",
+			"probe_http_status_code 204\n",
+		} {
+			if !strings.Contains(rec.Body.String(), expected) {
+				t.Errorf("missing metric %q in:\n%s", expected, rec.Body.String())
+			}
+		}
+	})
+
+	t.Run("tcp target is probed as is", func(t *testing.T) {
+		var probed health.Check
+		prober := &fakeProber{
+			probeFn: func(ctx context.Context, c health.Check) health.Result {
+				probed = c
+				return health.Result{OK: true}
+			},
+		}
+
+		h := health.NewProbeHandler(prober, health.DefaultModules())
+
+		req := httptest.NewRequest(http.MethodGet, "/probe?target=db.example.com:5432&module=tcp_connect", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+		equal(t, health.TypeTCP, probed.Type, "unexpected type")
+		equal(t, "db.example.com:5432", probed.Endpoint, "unexpected target")
+		if strings.Contains(rec.Body.String(), "probe_http_status_code") {
+			t.Errorf("unexpected http status code in:\n%s", rec.Body.String())
+		}
+	})
+
+	t.Run("probe is bounded by the prometheus scrape timeout", func(t *testing.T) {
+		var deadline time.Duration
+		prober := &fakeProber{
+			probeFn: func(ctx context.Context, c health.Check) health.Result {
+				d, _ := ctx.Deadline()
+				deadline = time.Until(d)
+				return health.Result{}
+			},
+		}
+
+		h := health.NewProbeHandler(prober, health.DefaultModules())
+
+		req := httptest.NewRequest(http.MethodGet, "/probe?target=http://example.com", nil)
+		req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "2")
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		mustEqual(t, http.StatusOK, rec.Code, "bad status code")
+		if deadline > 1500*time.Millisecond {
+			t.Errorf("probe not bounded by the scrape timeout: got=%s", deadline)
+		}
+		if !strings.Contains(rec.Body.String(), "probe_success 0\n") {
+			t.Errorf("expected failed probe in:\n%s", rec.Body.String())
+		}
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			rawQuery string
+		}{
+			{name: "missing target", rawQuery: "module=http_2xx"},
+			{name: "unknown module", rawQuery: "target=example.com&module=icmp"},
+			{name: "invalid target", rawQuery: "target=http:///nohost"},
+			{name: "tcp target without port", rawQuery: "target=example.com&module=tcp_connect"},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				h := health.NewProbeHandler(&fakeProber{}, health.DefaultModules())
+
+				req := httptest.NewRequest(http.MethodGet, "/probe?"+tt.rawQuery, nil)
+				rec := httptest.NewRecorder()
+
+				h.ServeHTTP(rec, req)
+
+				equal(t, http.StatusBadRequest, rec.Code, "bad status code")
+			}
+
+			t.Run(tt.name, fn)
+		}
+	})
+	t.Run("modules are validated", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			module    health.Check
+			shouldErr bool
+		}{
+			{name: "expectation", module: health.Check{Expect: &health.Expectation{Codes: "200-299", BodyRegex: "^ok$"}}},
+			{name: "timeout beyond the default interval", module: health.Check{Timeout: health.Duration(time.Minute)}},
+			{name: "dns record type", module: health.Check{Type: health.TypeDNS, DNS: &health.DNSQuery{RecordType: "MX"}}},
+			{name: "unknown type", module: health.Check{Type: "icmp"}, shouldErr: true},
+			{name: "invalid codes", module: health.Check{Expect: &health.Expectation{Codes: "600"}}, shouldErr: true},
+			{name: "invalid body regex", module: health.Check{Expect: &health.Expectation{BodyRegex: "("}}, shouldErr: true},
+			{name: "standard headers", module: health.Check{Request: &health.Request{Headers: map[string]string{"Accept": "application/json", "User-Agent": "prober"}}}},
+			{name: "auth", module: health.Check{Request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, Token: "token"}}}, shouldErr: true},
+			{name: "auth secret ref", module: health.Check{Request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "env:HEALTH_SECRET_TOKEN"}}}, shouldErr: true},
+			{name: "secret header", module: health.Check{Request: &health.Request{SecretHeaders: map[string]string{"X-Auth": "env:HEALTH_SECRET_TOKEN"}}}, shouldErr: true},
+			{name: "custom auth header", module: health.Check{Request: &health.Request{Headers: map[string]string{"X-Auth": "s3cr3t"}}}, shouldErr: true},
+			{name: "invalid record type", module: health.Check{Type: health.TypeDNS, DNS: &health.DNSQuery{RecordType: "SOA"}}, shouldErr: true},
+			{name: "negative timeout", module: health.Check{Timeout: health.Duration(-time.Second)}, shouldErr: true},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				modules := health.DefaultModules()
+				modules["custom"] = tt.module
+
+				err := health.ValidateModules(modules, nil)
+				if tt.shouldErr {
+					mustError(t, err)
+					return
+				}
+				mustNoError(t, err)
+			}
+
+			t.Run(tt.name, fn)
+		}
+	})
+}
@@ -0,0 +1,326 @@
+package health_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestHTTPProber(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		expectedOK bool
+	}{
+		{name: "2xx is healthy", statusCode: http.StatusOK, expectedOK: true},
+		{name: "4xx is unhealthy", statusCode: http.StatusNotFound, expectedOK: false},
+		{name: "5xx is unhealthy", statusCode: http.StatusServiceUnavailable, expectedOK: false},
+	}
+
+	for _, tt := range tests {
+		fn := func(t *testing.T) {
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer svr.Close()
+
+			prober := health.NewHTTPProber(svr.Client(), nil)
+
+			res := prober.Probe(context.Background(), health.Check{ID: "id", Endpoint: svr.URL})
+
+			equal(t, "id", res.CheckID, "unexpected check id")
+			equal(t, tt.expectedOK, res.OK, "unexpected ok")
+			equal(t, int32(tt.statusCode), res.Code, "unexpected code")
+			equal(t, "", res.Error, "unexpected error")
+			if res.Checked.IsZero() {
+				t.Error("checked time not set")
+			}
+			if res.Duration <= 0 {
+				t.Error("duration not set")
+			}
+		}
+
+		t.Run(tt.name, fn)
+	}
+
+	t.Run("expectations", func(t *testing.T) {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Version", "v2")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"status":"degraded","checks":[{"name":"db","ok":true}],"uptime":99.5}`))
+		}))
+		defer svr.Close()
+
+		tests := []struct {
+			name              string
+			expect            *health.Expectation
+			expectedAssertion string
+		}{
+			{name: "default codes", expectedAssertion: ""},
+			{name: "code in range", expect: &health.Expectation{Codes: "200-204"}},
+			{name: "code not in list", expect: &health.Expectation{Codes: "201,204-299"}, expectedAssertion: health.AssertionCodes},
+			{name: "header present", expect: &health.Expectation{Headers: map[string]string{"x-version": ""}}},
+			{name: "header value matches", expect: &health.Expectation{Headers: map[string]string{"X-Version": "v2"}}},
+			{name: "header value differs", expect: &health.Expectation{Headers: map[string]string{"X-Version": "v3"}}, expectedAssertion: health.AssertionHeaders},
+			{name: "header missing", expect: &health.Expectation{Headers: map[string]string{"X-Request-Id": ""}}, expectedAssertion: health.AssertionHeaders},
+			{name: "body contains", expect: &health.Expectation{Body: `"name":"db"`}},
+			{name: "body does not contain", expect: &health.Expectation{Body: `"status":"ok"`}, expectedAssertion: health.AssertionBody},
+			{name: "body matches regex", expect: &health.Expectation{BodyRegex: `"uptime":\d+`}},
+			{name: "body does not match regex", expect: &health.Expectation{BodyRegex: `^ok$`}, expectedAssertion: health.AssertionBodyRegex},
+			{name: "json path present", expect: &health.Expectation{JSONPath: "$.checks[0].name"}},
+			{name: "json path bool value", expect: &health.Expectation{JSONPath: "$.checks[0].ok", JSONValue: "true"}},
+			{name: "json path number value", expect: &health.Expectation{JSONPath: "$.uptime", JSONValue: "99.5"}},
+			{name: "json path degraded status", expect: &health.Expectation{JSONPath: "$.status", JSONValue: "ok"}, expectedAssertion: health.AssertionJSONPath},
+			{name: "json path missing", expect: &health.Expectation{JSONPath: "$.checks[1].name"}, expectedAssertion: health.AssertionJSONPath},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				prober := health.NewHTTPProber(svr.Client(), nil)
+
+				res := prober.Probe(context.Background(), health.Check{ID: "id", Endpoint: svr.URL, Expect: tt.expect})
+
+				equal(t, tt.expectedAssertion == "", res.OK, "unexpected ok")
+				equal(t, tt.expectedAssertion, res.Assertion, "unexpected failed assertion")
+				equal(t, tt.expectedAssertion == "", res.Failure == "", "unexpected failure: "+res.Failure)
+				equal(t, int32(http.StatusOK), res.Code, "unexpected code")
+			}
+
+			t.Run(tt.name, fn)
+		}
+	})
+
+	t.Run("custom request", func(t *testing.T) {
+		tmpDir, err := ioutil.TempDir("", "")
+		mustNoError(t, err)
+		defer os.RemoveAll(tmpDir)
+
+		secretsDir := filepath.Join(tmpDir, "secrets")
+		mustNoError(t, os.Mkdir(secretsDir, 0700))
+		mustNoError(t, ioutil.WriteFile(filepath.Join(secretsDir, "secret"), []byte("file-secret\n"), 0600))
+		secrets := health.NewSecrets(secretsDir)
+
+		os.Setenv("HEALTH_SECRET_TEST", "env-secret")
+		defer os.Unsetenv("HEALTH_SECRET_TEST")
+
+		type received struct {
+			method, body, authorization, contentType string
+		}
+
+		tests := []struct {
+			name     string
+			request  *health.Request
+			expected received
+		}{
+			{
+				name:     "bare GET",
+				expected: received{method: http.MethodGet},
+			},
+			{
+				name: "POST with headers and body",
+				request: &health.Request{
+					Method:  "post",
+					Headers: map[string]string{"Content-Type": "application/json"},
+					Body:    `{"ping":true}`,
+				},
+				expected: received{method: http.MethodPost, body: `{"ping":true}`, contentType: "application/json"},
+			},
+			{
+				name: "basic auth",
+				request: &health.Request{
+					Auth: &health.Auth{Type: health.AuthBasic, Username: "user", Password: "pass"},
+				},
+				expected: received{method: http.MethodGet, authorization: "Basic dXNlcjpwYXNz"},
+			},
+			{
+				name: "bearer token",
+				request: &health.Request{
+					Auth: &health.Auth{Type: health.AuthBearer, Token: "token"},
+				},
+				expected: received{method: http.MethodGet, authorization: "Bearer token"},
+			},
+			{
+				name: "bearer token from env",
+				request: &health.Request{
+					Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "env:HEALTH_SECRET_TEST"},
+				},
+				expected: received{method: http.MethodGet, authorization: "Bearer env-secret"},
+			},
+			{
+				name: "bearer token from file",
+				request: &health.Request{
+					Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "file:secret"},
+				},
+				expected: received{method: http.MethodGet, authorization: "Bearer file-secret"},
+			},
+			{
+				name: "header from secret ref",
+				request: &health.Request{
+					SecretHeaders: map[string]string{"Authorization": "env:HEALTH_SECRET_TEST"},
+				},
+				expected: received{method: http.MethodGet, authorization: "env-secret"},
+			},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				var got received
+				svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					b, _ := ioutil.ReadAll(r.Body)
+					got = received{
+						method:        r.Method,
+						body:          string(b),
+						authorization: r.Header.Get("Authorization"),
+						contentType:   r.Header.Get("Content-Type"),
+					}
+				}))
+				defer svr.Close()
+
+				prober := health.NewHTTPProber(svr.Client(), secrets)
+
+				res := prober.Probe(context.Background(), health.Check{ID: "id", Endpoint: svr.URL, Request: tt.request})
+
+				equal(t, true, res.OK, "unexpected failure: "+res.Error)
+				equal(t, tt.expected, got, "unexpected request")
+			}
+
+			t.Run(tt.name, fn)
+		}
+
+		t.Run("unresolvable secret fails the probe", func(t *testing.T) {
+			prober := health.NewHTTPProber(nil, secrets)
+
+			res := prober.Probe(context.Background(), health.Check{
+				ID:       "id",
+				Endpoint: "http://example.com",
+				Request: &health.Request{
+					Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "env:HEALTH_SECRET_TEST_MISSING"},
+				},
+			})
+
+			equal(t, false, res.OK, "unexpected ok")
+			if res.Error == "" {
+				t.Error("expected an error to be recorded")
+			}
+		})
+
+		t.Run("secrets outside of the allowed refs are never sent", func(t *testing.T) {
+			outside := filepath.Join(tmpDir, "outside")
+			mustNoError(t, ioutil.WriteFile(outside, []byte("outside-secret"), 0600))
+			mustNoError(t, os.Symlink(outside, filepath.Join(secretsDir, "link")))
+			os.Setenv("HEALTH_TEST_SECRET", "env-secret")
+			defer os.Unsetenv("HEALTH_TEST_SECRET")
+
+			tests := []struct {
+				name    string
+				secrets *health.Secrets
+				ref     string
+			}{
+				{name: "env var without the prefix", secrets: secrets, ref: "env:HEALTH_TEST_SECRET"},
+				{name: "file outside the dir", secrets: secrets, ref: "file:" + outside},
+				{name: "file traversing out of the dir", secrets: secrets, ref: "file:../outside"},
+				{name: "symlink out of the dir", secrets: secrets, ref: "file:link"},
+				{name: "file without a dir", ref: "file:" + filepath.Join(secretsDir, "secret")},
+			}
+
+			for _, tt := range tests {
+				fn := func(t *testing.T) {
+					var requests int
+					svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						requests++
+					}))
+					defer svr.Close()
+
+					res := health.NewHTTPProber(svr.Client(), tt.secrets).Probe(context.Background(), health.Check{
+						ID:       "id",
+						Endpoint: svr.URL,
+						Request: &health.Request{
+							Auth: &health.Auth{Type: health.AuthBearer, SecretRef: tt.ref},
+						},
+					})
+
+					equal(t, false, res.OK, "unexpected ok")
+					equal(t, 0, requests, "unexpected requests")
+				}
+
+				t.Run(tt.name, fn)
+			}
+		})
+	})
+
+	t.Run("https endpoint records the certificate", func(t *testing.T) {
+		svr := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer svr.Close()
+
+		prober := health.NewHTTPProber(svr.Client(), nil)
+
+		res := prober.Probe(context.Background(), health.Check{ID: "id", Endpoint: svr.URL})
+
+		equal(t, true, res.OK, "unexpected ok")
+		if res.TLS == nil {
+			t.Fatal("expected tls info to be recorded")
+		}
+
+		leaf := svr.Certificate()
+		equal(t, true, leaf.NotAfter.Equal(res.TLS.Expiry), "unexpected expiry")
+		equal(t, leaf.Issuer.String(), res.TLS.Issuer, "unexpected issuer")
+		equal(t, "example.com", res.TLS.SANs[0], "unexpected sans")
+		equal(t, int(time.Until(leaf.NotAfter).Hours()/24), res.TLS.DaysRemaining, "unexpected days remaining")
+		mustEqual(t, 1, len(res.TLS.Chain), "unexpected chain")
+	})
+
+	t.Run("http endpoint records no certificate", func(t *testing.T) {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer svr.Close()
+
+		res := health.NewHTTPProber(nil, nil).Probe(context.Background(), health.Check{ID: "id", Endpoint: svr.URL})
+
+		equal(t, true, res.TLS == nil, "unexpected tls info")
+	})
+
+	t.Run("unreachable endpoint records the error", func(t *testing.T) {
+		svr := httptest.NewServer(http.NotFoundHandler())
+		endpoint := svr.URL
+		svr.Close()
+
+		prober := health.NewHTTPProber(nil, nil)
+
+		res := prober.Probe(context.Background(), health.Check{ID: "id", Endpoint: endpoint})
+
+		equal(t, false, res.OK, "unexpected ok")
+		equal(t, int32(0), res.Code, "unexpected code")
+		if res.Error == "" {
+			t.Error("expected an error to be recorded")
+		}
+	})
+}
+
+func TestProber(t *testing.T) {
+	t.Run("dispatches the check to the prober of its type", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		mustNoError(t, err)
+		defer l.Close()
+
+		res := health.NewProber(nil, nil).Probe(context.Background(), health.Check{ID: "id", Type: health.TypeTCP, Endpoint: l.Addr().String()})
+
+		equal(t, true, res.OK, "unexpected failure: "+res.Error)
+	})
+
+	t.Run("unknown type fails the probe", func(t *testing.T) {
+		res := health.NewProber(nil, nil).Probe(context.Background(), health.Check{ID: "id", Type: "icmp", Endpoint: "example.com"})
+
+		equal(t, "id", res.CheckID, "unexpected check id")
+		equal(t, false, res.OK, "unexpected ok")
+		if res.Error == "" {
+			t.Error("expected an error to be recorded")
+		}
+	})
+}
@@ -5,31 +5,109 @@ import (
 	"encoding/gob"
 	"errors"
 	"io"
-	"io/ioutil"
+	"log"
 	"os"
 	"sync"
+	"time"
 )
 
+// fileRepository persists the checks to a snapshot of every check, with the
+// mutations since the snapshot appended to a write-ahead log alongside it.
+// The log is compacted into the snapshot once it holds compactEvery records,
+// with a replaced snapshot kept as the latest backup once it is backupEvery
+// newer than the previous backup.
 type fileRepository struct {
-	filepath string
+	filepath     string
+	compactEvery int
+	backups      int
+	backupEvery  time.Duration
 
-	mu     *sync.Mutex
-	checks checks
+	// mu serializes the mutations, so the checks are mutated in the order
+	// they are logged
+	mu         *sync.Mutex
+	checks     *memRepository
+	wal        *os.File
+	walSize    int64
+	walRecords int
 }
 
 var _ Repository = (*fileRepository)(nil)
 
-func NewFileRepository(filepath string) (Repository, error) {
+const (
+	defaultCompactEvery = 1000
+	defaultBackups      = 3
+	defaultBackupEvery  = time.Hour
+)
+
+type FileRepositoryOption func(*fileRepository)
+
+// WithCompactEvery compacts the write-ahead log into the snapshot every n
+// records.
+func WithCompactEvery(n int) FileRepositoryOption {
+	return func(r *fileRepository) {
+		r.compactEvery = n
+	}
+}
+
+// WithBackups retains the last n snapshots as backups, at the path of the
+// snapshot suffixed with .1 through .n, .1 being the latest.
+func WithBackups(n int) FileRepositoryOption {
+	return func(r *fileRepository) {
+		r.backups = n
+	}
+}
+
+// WithBackupEvery retains a replaced snapshot as a backup once it was written
+// at least d after the latest backup.
+func WithBackupEvery(d time.Duration) FileRepositoryOption {
+	return func(r *fileRepository) {
+		r.backupEvery = d
+	}
+}
+
+func NewFileRepository(filepath string, opts ...FileRepositoryOption) (Repository, error) {
 	existingChecks, err := checksFromPersistence(filepath)
 	if err != nil {
 		return nil, err
 	}
 
-	return &fileRepository{
-		filepath: filepath,
-		mu:       new(sync.Mutex),
-		checks:   existingChecks,
-	}, nil
+	wal, err := os.OpenFile(walPath(filepath), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	records, size, tail, err := readWAL(wal)
+	if err != nil {
+		wal.Close()
+		return nil, err
+	}
+	if tail.size > 0 {
+		log.Printf("dropping %d bytes of the write-ahead log of %s following its last intact record, holding %d intact records", tail.size, filepath, tail.records)
+	}
+	// drop what remains of a record the process died appending
+	if err := wal.Truncate(size); err != nil {
+		wal.Close()
+		return nil, err
+	}
+
+	r := &fileRepository{
+		filepath:     filepath,
+		compactEvery: defaultCompactEvery,
+		backups:      defaultBackups,
+		backupEvery:  defaultBackupEvery,
+		mu:           new(sync.Mutex),
+		checks:       replay(existingChecks, records),
+		wal:          wal,
+		walSize:      size,
+		walRecords:   len(records),
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r, nil
+}
+
+func walPath(filepath string) string {
+	return filepath + ".wal"
 }
 
 func checksFromPersistence(filepath string) ([]Check, error) {
@@ -39,7 +117,8 @@ func checksFromPersistence(filepath string) ([]Check, error) {
 			return nil, err
 		}
 
-		f, err = os.Create(filepath)
+		// the checks hold the secrets used to probe them
+		f, err = os.OpenFile(filepath, os.O_CREATE|os.O_RDWR, 0600)
 		if err != nil {
 			return nil, err
 		}
@@ -60,69 +139,115 @@ func (r *fileRepository) Create(check Check) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, found := r.checks.find(check.ID); found {
+	if _, err := r.checks.Read(check.ID); err == nil {
 		return errEndpointExists
 	}
 
-	newChecks := append(r.checks, check)
-
-	if err := r.toDisk(newChecks); err != nil {
+	if err := r.appendWAL(walRecord{Op: walCreate, Check: check}, true); err != nil {
 		return err
 	}
 
-	r.checks = newChecks
+	if err := r.checks.Create(check); err != nil {
+		return err
+	}
+	r.maybeCompact()
 	return nil
 }
 
 func (r *fileRepository) List(page, size int) (int, []Check) {
+	return r.checks.List(page, size)
+}
+
+var errCheckNotFound = errors.New("check not found by the provided id")
+
+func (r *fileRepository) Read(id string) (Check, error) {
+	return r.checks.Read(id)
+}
+
+func (r *fileRepository) Update(check Check) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	total := len(r.checks)
-	if size == -1 {
-		return total, r.checks
+	if _, err := r.checks.Read(check.ID); err != nil {
+		return err
 	}
 
-	start := size * (page - 1)
-	if start >= len(r.checks) {
-		return total, []Check{}
+	// updates are the outcome of probes, the next probe recovers an update
+	// lost to a crash, so the log is not synced for them.
+	if err := r.appendWAL(walRecord{Op: walUpdate, Check: check}, false); err != nil {
+		return err
 	}
 
-	end := size * (page)
-	if end > len(r.checks) {
-		end = len(r.checks)
+	if err := r.checks.Update(check); err != nil {
+		return err
 	}
-
-	return total, r.checks[start:end]
+	r.maybeCompact()
+	return nil
 }
 
-var errCheckNotFound = errors.New("check not found by the provided id")
-
-func (r *fileRepository) Read(id string) (Check, error) {
+func (r *fileRepository) Delete(id string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	check, found := r.checks.find(id)
-	if !found {
-		return Check{}, errCheckNotFound
+	if _, err := r.checks.Read(id); err != nil {
+		return nil
 	}
-	return check, nil
+
+	if err := r.appendWAL(walRecord{Op: walDelete, Check: Check{ID: id}}, true); err != nil {
+		return err
+	}
+
+	if err := r.checks.Delete(id); err != nil {
+		return err
+	}
+	r.maybeCompact()
+	return nil
 }
 
-func (r *fileRepository) Delete(id string) error {
-	out := make([]Check, 0, len(r.checks)-1)
-	for _, check := range r.checks {
-		if id == check.ID {
-			continue
-		}
-		out = append(out, check)
+func (r *fileRepository) appendWAL(rec walRecord, sync bool) error {
+	b, err := encodeWALRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.wal.Write(b)
+	if err == nil && sync {
+		err = r.wal.Sync()
+	}
+	if err != nil {
+		// drop the record so the log neither holds a mutation that failed,
+		// nor loses the records appended after a partially written one.
+		r.wal.Truncate(r.walSize)
+		return err
 	}
 
-	if err := r.toDisk(out); err != nil {
+	r.walSize += int64(len(b))
+	r.walRecords++
+	return nil
+}
+
+// maybeCompact compacts the log into the snapshot once it holds compactEvery
+// records. The mutations are in the log already, a failed compaction is
+// retried with the next mutation.
+func (r *fileRepository) maybeCompact() {
+	if r.compactEvery <= 0 || r.walRecords < r.compactEvery {
+		return
+	}
+	if err := r.compact(); err != nil {
+		log.Printf("failed to compact the write-ahead log of %s: %s", r.filepath, err)
+	}
+}
+
+func (r *fileRepository) compact() error {
+	_, checks := r.checks.List(0, -1)
+	if err := r.toDisk(checks); err != nil {
+		return err
+	}
+	if err := r.wal.Truncate(0); err != nil {
 		return err
 	}
 
-	r.checks = out
+	r.walSize, r.walRecords = 0, 0
 	return nil
 }
 
@@ -133,16 +258,117 @@ func (r *fileRepository) toDisk(c []Check) error {
 		return err
 	}
 
-	return ioutil.WriteFile(r.filepath, buf.Bytes(), 666)
+	// the snapshot is written before the backups are rotated, so a snapshot
+	// that fails to be written leaves the backups as they were
+	tmp, err := writeTempFile(r.filepath, buf.Bytes(), 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if err := rotateBackups(r.filepath, r.backups, r.backupEvery); err != nil {
+		return err
+	}
+	return replaceFile(tmp, r.filepath)
+}
+
+type memRepository struct {
+	mu     sync.Mutex
+	checks map[string]Check
+	// order holds the ids of the checks in the order they were created in
+	order []string
+}
+
+var _ Repository = (*memRepository)(nil)
+
+// NewMemoryRepository creates a repository that keeps the checks in memory.
+func NewMemoryRepository() Repository {
+	return newMemRepository()
+}
+
+func newMemRepository() *memRepository {
+	return &memRepository{checks: make(map[string]Check)}
+}
+
+func (r *memRepository) Create(check Check) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, found := r.checks[check.ID]; found {
+		return errEndpointExists
+	}
+
+	r.checks[check.ID] = check
+	r.order = append(r.order, check.ID)
+	return nil
+}
+
+func (r *memRepository) List(page, size int) (int, []Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := len(r.order)
+	ids := r.order
+	if size != -1 {
+		start := size * (page - 1)
+		if start < 0 {
+			start = 0
+		}
+		if start >= total {
+			return total, []Check{}
+		}
+
+		end := start + size
+		if end > total {
+			end = total
+		}
+		ids = ids[start:end]
+	}
+
+	out := make([]Check, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, r.checks[id])
+	}
+	return total, out
+}
+
+func (r *memRepository) Read(id string) (Check, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	check, found := r.checks[id]
+	if !found {
+		return Check{}, errCheckNotFound
+	}
+	return check, nil
+}
+
+func (r *memRepository) Update(check Check) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, found := r.checks[check.ID]; !found {
+		return errCheckNotFound
+	}
+	r.checks[check.ID] = check
+	return nil
 }
 
-type checks []Check
+func (r *memRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, found := r.checks[id]; !found {
+		return nil
+	}
+	delete(r.checks, id)
 
-func (c checks) find(id string) (Check, bool) {
-	for _, check := range c {
-		if check.ID == id {
-			return check, true
+	order := make([]string, 0, len(r.order)-1)
+	for _, orderedID := range r.order {
+		if orderedID != id {
+			order = append(order, orderedID)
 		}
 	}
-	return Check{}, false
+	r.order = order
+	return nil
 }
@@ -1,12 +1,19 @@
 package health_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
 	"encoding/gob"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jsteenb2/health/internal/health"
 )
@@ -96,8 +103,11 @@ func TestFileRepository(t *testing.T) {
 			err = repo.Create(newCheck)
 			mustNoError(t, err)
 
-			checks := readChecksFromFile(t, file)
-			mustEqual(t, 1, len(checks), "wrong number of checks found")
+			repo, err = health.NewFileRepository(file)
+			mustNoError(t, err)
+
+			total, checks := repo.List(0, -1)
+			mustEqual(t, 1, total, "wrong number of checks found")
 			equal(t, newCheck, checks[0], "check bounced")
 		})
 
@@ -121,6 +131,321 @@ func TestFileRepository(t *testing.T) {
 		})
 	})
 
+	t.Run("update", func(t *testing.T) {
+		t.Run("persists the updated check", func(t *testing.T) {
+			tmpDir := newTempDir(t)
+			defer os.RemoveAll(tmpDir)
+
+			filePath := filepath.Join(tmpDir, "tmp_file")
+
+			existingCheck := health.Check{ID: "id", Endpoint: "endpoint"}
+			newFileWithChecks(t, filePath, existingCheck)
+
+			repo, err := health.NewFileRepository(filePath)
+			mustNoError(t, err)
+
+			updatedCheck := existingCheck
+			updatedCheck.State = health.StateUp
+			updatedCheck.Code = 200
+			mustNoError(t, repo.Update(updatedCheck))
+
+			check, err := repo.Read(existingCheck.ID)
+			mustNoError(t, err)
+			equal(t, updatedCheck, check, "check not updated")
+
+			repo, err = health.NewFileRepository(filePath)
+			mustNoError(t, err)
+
+			total, checks := repo.List(0, -1)
+			mustEqual(t, 1, total, "wrong number of checks found")
+			equal(t, updatedCheck, checks[0], "check not persisted")
+		})
+
+		t.Run("fails when check does not exist", func(t *testing.T) {
+			tmpDir := newTempDir(t)
+			defer os.RemoveAll(tmpDir)
+
+			repo, err := health.NewFileRepository(filepath.Join(tmpDir, "tmp_file"))
+			mustNoError(t, err)
+
+			mustError(t, repo.Update(health.Check{ID: "id"}))
+		})
+	})
+
+	t.Run("write-ahead log", func(t *testing.T) {
+		// mutate creates, updates and deletes checks, providing the checks
+		// after every mutation.
+		mutate := func(t *testing.T, repo health.Repository) [][]health.Check {
+			t.Helper()
+
+			snapshot := func() []health.Check {
+				_, checks := repo.List(0, -1)
+				return append([]health.Check{}, checks...)
+			}
+
+			states := [][]health.Check{snapshot()}
+			for i := 0; i < 3; i++ {
+				mustNoError(t, repo.Create(health.Check{ID: "id-" + strconv.Itoa(i), Endpoint: "http://example.com"}))
+				states = append(states, snapshot())
+			}
+			mustNoError(t, repo.Update(health.Check{ID: "id-1", Endpoint: "http://example.com", State: health.StateDown, Code: 503}))
+			states = append(states, snapshot())
+			mustNoError(t, repo.Delete("id-0"))
+			states = append(states, snapshot())
+			mustNoError(t, repo.Update(health.Check{ID: "id-2", Endpoint: "http://example.com", State: health.StateUp, Code: 200}))
+			return append(states, snapshot())
+		}
+
+		// stateIndex provides the index of the checks amongst the states,
+		// -1 when the checks are none of them.
+		stateIndex := func(states [][]health.Check, checks []health.Check) int {
+			for i, state := range states {
+				if len(state) == 0 && len(checks) == 0 {
+					return i
+				}
+				if reflect.DeepEqual(state, checks) {
+					return i
+				}
+			}
+			return -1
+		}
+
+		t.Run("compacts the log into the snapshot", func(t *testing.T) {
+			tmpDir := newTempDir(t)
+			defer os.RemoveAll(tmpDir)
+
+			filePath := filepath.Join(tmpDir, "tmp_file")
+			repo, err := health.NewFileRepository(filePath, health.WithCompactEvery(4))
+			mustNoError(t, err)
+
+			states := mutate(t, repo)
+
+			// compacted after the 4th mutation, with 2 mutations logged since
+			checks := readChecksFromFile(t, filePath)
+			equal(t, states[4], checks, "unexpected snapshot")
+
+			repo, err = health.NewFileRepository(filePath)
+			mustNoError(t, err)
+
+			_, checks = repo.List(0, -1)
+			equal(t, states[len(states)-1], checks, "unexpected checks")
+		})
+
+		t.Run("recovers from a log truncated at any offset", func(t *testing.T) {
+			tmpDir := newTempDir(t)
+			defer os.RemoveAll(tmpDir)
+
+			existingCheck := health.Check{ID: "id", Endpoint: "endpoint"}
+			filePath := filepath.Join(tmpDir, "tmp_file")
+			newFileWithChecks(t, filePath, existingCheck)
+
+			repo, err := health.NewFileRepository(filePath)
+			mustNoError(t, err)
+
+			states := mutate(t, repo)
+
+			snapshot, err := ioutil.ReadFile(filePath)
+			mustNoError(t, err)
+			wal, err := ioutil.ReadFile(filePath + ".wal")
+			mustNoError(t, err)
+
+			// a few hundred offsets spread over the log, every record carries
+			// its gob type definitions making for a log of several kilobytes
+			offsets := []int{len(wal)}
+			for offset := len(wal) - 1; offset >= 0; offset -= len(wal)/250 + 1 {
+				offsets = append([]int{offset}, offsets...)
+			}
+
+			lastState := 0
+			for _, offset := range offsets {
+				crashedPath := filepath.Join(tmpDir, "crashed_"+strconv.Itoa(offset))
+				mustNoError(t, ioutil.WriteFile(crashedPath, snapshot, 0600))
+				mustNoError(t, ioutil.WriteFile(crashedPath+".wal", wal[:offset], 0600))
+
+				repo, err := health.NewFileRepository(crashedPath)
+				mustNoError(t, err)
+
+				_, checks := repo.List(0, -1)
+				idx := stateIndex(states, checks)
+				if idx < lastState {
+					t.Fatalf("offset %d: unexpected checks: %#v", offset, checks)
+				}
+				lastState = idx
+
+				// the remains of a torn record must not hide the records
+				// appended after it
+				newCheck := health.Check{ID: "new-id", Endpoint: "http://example.com"}
+				mustNoError(t, repo.Create(newCheck))
+
+				repo, err = health.NewFileRepository(crashedPath)
+				mustNoError(t, err)
+
+				check, err := repo.Read(newCheck.ID)
+				mustNoError(t, err)
+				equal(t, newCheck, check, "unexpected check")
+			}
+			equal(t, len(states)-1, lastState, "unexpected checks with the whole log")
+		})
+
+		t.Run("stops replaying at a corrupt record", func(t *testing.T) {
+			tmpDir := newTempDir(t)
+			defer os.RemoveAll(tmpDir)
+
+			filePath := filepath.Join(tmpDir, "tmp_file")
+			repo, err := health.NewFileRepository(filePath)
+			mustNoError(t, err)
+
+			states := mutate(t, repo)
+
+			wal, err := ioutil.ReadFile(filePath + ".wal")
+			mustNoError(t, err)
+			wal[len(wal)/2] ^= 0xff
+			mustNoError(t, ioutil.WriteFile(filePath+".wal", wal, 0600))
+
+			repo, err = health.NewFileRepository(filePath)
+			mustNoError(t, err)
+
+			_, checks := repo.List(0, -1)
+			idx := stateIndex(states, checks)
+			if idx == -1 || idx == len(states)-1 {
+				t.Fatalf("unexpected checks: %#v", checks)
+			}
+		})
+
+		t.Run("logs the intact records dropped following a corrupt record", func(t *testing.T) {
+			tmpDir := newTempDir(t)
+			defer os.RemoveAll(tmpDir)
+
+			filePath := filepath.Join(tmpDir, "tmp_file")
+			repo, err := health.NewFileRepository(filePath)
+			mustNoError(t, err)
+
+			states := mutate(t, repo)
+
+			// corrupt the payload of the first record, leaving its header
+			// to frame the records following it
+			wal, err := ioutil.ReadFile(filePath + ".wal")
+			mustNoError(t, err)
+			size := 8 + int(binary.BigEndian.Uint32(wal[0:4]))
+			wal[size-1] ^= 0xff
+			mustNoError(t, ioutil.WriteFile(filePath+".wal", wal, 0600))
+
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			defer log.SetOutput(os.Stderr)
+
+			repo, err = health.NewFileRepository(filePath)
+			mustNoError(t, err)
+
+			_, checks := repo.List(0, -1)
+			equal(t, states[0], checks, "unexpected checks")
+
+			expected := "dropping " + strconv.Itoa(len(wal)) + " bytes of the write-ahead log of " + filePath + " following its last intact record, holding " + strconv.Itoa(len(states)-2) + " intact records"
+			if !strings.Contains(buf.String(), expected) {
+				t.Errorf("missing %q in log:\n%s", expected, buf.String())
+			}
+		})
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		t.Run("is written with owner only permissions", func(t *testing.T) {
+			tmpDir := newTempDir(t)
+			defer os.RemoveAll(tmpDir)
+
+			filePath := filepath.Join(tmpDir, "tmp_file")
+			repo, err := health.NewFileRepository(filePath, health.WithCompactEvery(1))
+			mustNoError(t, err)
+
+			mustNoError(t, repo.Create(health.Check{ID: "id", Endpoint: "http://example.com"}))
+
+			for _, path := range []string{filePath, filePath + ".wal"} {
+				stats, err := os.Stat(path)
+				mustNoError(t, err)
+				equal(t, os.FileMode(0600), stats.Mode().Perm(), "unexpected permissions of "+filepath.Base(path))
+			}
+
+			files, err := ioutil.ReadDir(tmpDir)
+			mustNoError(t, err)
+			equal(t, 2, len(files), "unexpected files left behind")
+		})
+
+		t.Run("retains the replaced snapshots as backups", func(t *testing.T) {
+			tmpDir := newTempDir(t)
+			defer os.RemoveAll(tmpDir)
+
+			filePath := filepath.Join(tmpDir, "tmp_file")
+			repo, err := health.NewFileRepository(filePath, health.WithCompactEvery(1), health.WithBackups(2), health.WithBackupEvery(0))
+			mustNoError(t, err)
+
+			var created []health.Check
+			for i := 0; i < 4; i++ {
+				check := health.Check{ID: "id-" + strconv.Itoa(i), Endpoint: "http://example.com"}
+				mustNoError(t, repo.Create(check))
+				created = append(created, check)
+			}
+
+			equal(t, created, readChecksFromFile(t, filePath), "unexpected snapshot")
+			equal(t, created[:3], readChecksFromFile(t, filePath+".1"), "unexpected latest backup")
+			equal(t, created[:2], readChecksFromFile(t, filePath+".2"), "unexpected oldest backup")
+
+			_, err = os.Stat(filePath + ".3")
+			equal(t, true, os.IsNotExist(err), "unexpected backup beyond those retained")
+		})
+
+		t.Run("retains a backup once the snapshot is newer than the latest backup by the backup period", func(t *testing.T) {
+			tmpDir := newTempDir(t)
+			defer os.RemoveAll(tmpDir)
+
+			filePath := filepath.Join(tmpDir, "tmp_file")
+			repo, err := health.NewFileRepository(filePath, health.WithCompactEvery(1), health.WithBackups(2), health.WithBackupEvery(time.Hour))
+			mustNoError(t, err)
+
+			var created []health.Check
+			for i := 0; i < 4; i++ {
+				check := health.Check{ID: "id-" + strconv.Itoa(i), Endpoint: "http://example.com"}
+				mustNoError(t, repo.Create(check))
+				created = append(created, check)
+			}
+
+			equal(t, created, readChecksFromFile(t, filePath), "unexpected snapshot")
+			equal(t, created[:1], readChecksFromFile(t, filePath+".1"), "unexpected latest backup")
+
+			_, err = os.Stat(filePath + ".2")
+			equal(t, true, os.IsNotExist(err), "unexpected backup within the backup period")
+		})
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		t.Run("removes the check", func(t *testing.T) {
+			tmpDir := newTempDir(t)
+			defer os.RemoveAll(tmpDir)
+
+			filePath := filepath.Join(tmpDir, "tmp_file")
+			newFileWithChecks(t, filePath, health.Check{ID: "id-1"}, health.Check{ID: "id-2"})
+
+			repo, err := health.NewFileRepository(filePath)
+			mustNoError(t, err)
+
+			mustNoError(t, repo.Delete("id-1"))
+
+			repo, err = health.NewFileRepository(filePath)
+			mustNoError(t, err)
+
+			_, checks := repo.List(0, -1)
+			equal(t, []health.Check{{ID: "id-2"}}, checks, "unexpected checks")
+		})
+
+		t.Run("from an empty repository", func(t *testing.T) {
+			tmpDir := newTempDir(t)
+			defer os.RemoveAll(tmpDir)
+
+			repo, err := health.NewFileRepository(filepath.Join(tmpDir, "tmp_file"))
+			mustNoError(t, err)
+
+			mustNoError(t, repo.Delete("id"))
+		})
+	})
+
 	t.Run("list", func(t *testing.T) {
 		stubChecks := make([]health.Check, 0, 20)
 		for i := range make([]struct{}, 20) {
@@ -214,6 +539,119 @@ func TestFileRepository(t *testing.T) {
 	})
 }
 
+func TestMemoryRepository(t *testing.T) {
+	newChecks := func(n int) []health.Check {
+		checks := make([]health.Check, 0, n)
+		for i := 0; i < n; i++ {
+			checks = append(checks, health.Check{ID: "id-" + strconv.Itoa(i), Endpoint: "http://example.com/" + strconv.Itoa(i)})
+		}
+		return checks
+	}
+
+	t.Run("create", func(t *testing.T) {
+		repo := health.NewMemoryRepository()
+
+		check := health.Check{ID: "id", Endpoint: "http://example.com"}
+		mustNoError(t, repo.Create(check))
+		mustError(t, repo.Create(check))
+
+		got, err := repo.Read(check.ID)
+		mustNoError(t, err)
+		equal(t, check, got, "unexpected check")
+	})
+
+	t.Run("update", func(t *testing.T) {
+		repo := health.NewMemoryRepository()
+
+		check := health.Check{ID: "id", Endpoint: "http://example.com"}
+		mustNoError(t, repo.Create(check))
+
+		check.State = health.StateUp
+		mustNoError(t, repo.Update(check))
+
+		got, err := repo.Read(check.ID)
+		mustNoError(t, err)
+		equal(t, check, got, "check not updated")
+
+		mustError(t, repo.Update(health.Check{ID: "missing"}))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := health.NewMemoryRepository()
+
+		checks := newChecks(3)
+		for _, c := range checks {
+			mustNoError(t, repo.Create(c))
+		}
+
+		mustNoError(t, repo.Delete(checks[1].ID))
+		mustNoError(t, repo.Delete("missing"))
+
+		_, err := repo.Read(checks[1].ID)
+		mustError(t, err)
+
+		total, got := repo.List(0, -1)
+		equal(t, 2, total, "unexpected total")
+		equal(t, []health.Check{checks[0], checks[2]}, got, "unexpected checks")
+
+		t.Run("from an empty repository", func(t *testing.T) {
+			mustNoError(t, health.NewMemoryRepository().Delete("id"))
+		})
+	})
+
+	t.Run("list", func(t *testing.T) {
+		repo := health.NewMemoryRepository()
+
+		checks := newChecks(20)
+		for _, c := range checks {
+			mustNoError(t, repo.Create(c))
+		}
+
+		tests := []struct {
+			name       string
+			page, size int
+			expected   []health.Check
+		}{
+			{name: "first page", page: 1, size: 5, expected: checks[:5]},
+			{name: "middle page", page: 3, size: 5, expected: checks[10:15]},
+			{name: "partial page", page: 2, size: 19, expected: checks[19:]},
+			{name: "page that does not exist", page: 100, size: 10, expected: []health.Check{}},
+			{name: "all checks", size: -1, expected: checks},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				total, got := repo.List(tt.page, tt.size)
+
+				equal(t, len(checks), total, "unexpected total")
+				equal(t, tt.expected, got, "unexpected checks")
+			}
+
+			t.Run(tt.name, fn)
+		}
+	})
+
+	t.Run("backs the service", func(t *testing.T) {
+		prober := &fakeProber{
+			probeFn: func(ctx context.Context, c health.Check) health.Result {
+				return health.Result{CheckID: c.ID, OK: true, Code: 200, Checked: time.Now()}
+			},
+		}
+		svc := health.NewSVC(health.NewMemoryRepository(), health.WithProber(prober))
+
+		check, err := svc.Create(health.Check{Endpoint: "http://example.com"})
+		mustNoError(t, err)
+
+		check, err = svc.Run(context.Background(), check.ID)
+		mustNoError(t, err)
+		equal(t, health.StateUp, check.State, "unexpected state")
+
+		total, _, checks := svc.List(1)
+		equal(t, 1, total, "unexpected total")
+		equal(t, []health.Check{check}, checks, "unexpected checks")
+	})
+}
+
 func mustNoError(t *testing.T, err error) {
 	t.Helper()
 
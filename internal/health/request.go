@@ -0,0 +1,329 @@
+package health
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Request customizes the request issued by the probe of a check. A check
+// without a request is probed with a bare GET.
+type Request struct {
+	Method string `json:"method,omitempty"`
+	// Headers are set on the request. The values of the headers other
+	// than a few standard ones, see plainHeaders, are treated as secrets.
+	Headers map[string]string `json:"headers,omitempty"`
+	// SecretHeaders are set on the request with their values resolved from
+	// the secret refs they hold, see Auth.SecretRef.
+	SecretHeaders map[string]string `json:"secret_headers,omitempty"`
+	Body          string            `json:"body,omitempty"`
+	Auth          *Auth             `json:"auth,omitempty"`
+}
+
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+)
+
+type Auth struct {
+	Type     string `json:"type"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+	// SecretRef references the password or token instead of it being
+	// persisted with the check. It is either "env:HEALTH_SECRET_NAME", to
+	// read the secret from an environment variable, or "file:name", to read
+	// it from a file within the secrets dir, see Secrets.
+	SecretRef string `json:"secret_ref,omitempty"`
+}
+
+const redacted = "REDACTED"
+
+var (
+	errInvalidMethod       = errors.New("method must be one of GET, HEAD, POST, PUT, PATCH, DELETE or OPTIONS")
+	errInvalidAuth         = errors.New("auth must be of type basic, with a username, or bearer, with either a password/token or a secret_ref")
+	errInvalidSecretHeader = errors.New("secret_headers must map header names to secret refs")
+	errInvalidSecretRef    = errors.New("secret_ref must be of the form env:" + SecretEnvPrefix + "NAME or file:name, naming a file within the secrets dir")
+)
+
+// SecretEnvPrefix is the prefix of the environment variables a secret_ref can
+// reference.
+const SecretEnvPrefix = "HEALTH_SECRET_"
+
+// Secrets resolves the secret refs of checks. As the secret is sent to the
+// endpoint of the check, whoever creates the check chooses where it goes, so
+// a ref is limited to the environment variables prefixed with SecretEnvPrefix
+// and the files within the dir configured by the operator. A nil Secrets, or
+// one without a dir, resolves no files.
+type Secrets struct {
+	dir string
+}
+
+func NewSecrets(dir string) *Secrets {
+	if dir != "" {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+	}
+	return &Secrets{dir: dir}
+}
+
+func (s *Secrets) validate(ref string) error {
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		if !strings.HasPrefix(name, SecretEnvPrefix) || name == SecretEnvPrefix {
+			return errInvalidSecretRef
+		}
+		return nil
+	case strings.HasPrefix(ref, "file:"):
+		_, err := s.path(strings.TrimPrefix(ref, "file:"))
+		return err
+	}
+	return errInvalidSecretRef
+}
+
+// path provides the path of the named file, which must be within the dir.
+// The name is either relative to the dir or an absolute path within it.
+func (s *Secrets) path(name string) (string, error) {
+	if s == nil || s.dir == "" || name == "" {
+		return "", errInvalidSecretRef
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.dir, path)
+	}
+	if !within(s.dir, path) {
+		return "", errInvalidSecretRef
+	}
+	return filepath.Clean(path), nil
+}
+
+func (s *Secrets) resolve(ref string) (string, error) {
+	if err := s.validate(ref); err != nil {
+		return "", err
+	}
+
+	if strings.HasPrefix(ref, "env:") {
+		name := strings.TrimPrefix(ref, "env:")
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", errors.New("secret env var " + name + " is not set")
+		}
+		return v, nil
+	}
+
+	path, err := s.path(strings.TrimPrefix(ref, "file:"))
+	if err != nil {
+		return "", err
+	}
+	// a symlink within the dir must not lead out of it
+	dir, err := filepath.EvalSymlinks(s.dir)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if !within(dir, resolved) {
+		return "", errInvalidSecretRef
+	}
+
+	b, err := ioutil.ReadFile(resolved)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// within reports whether the path is the dir or below it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && rel != "."
+}
+
+func validateRequest(r *Request, secrets *Secrets) error {
+	if r == nil {
+		return nil
+	}
+
+	switch strings.ToUpper(r.Method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		return errInvalidMethod
+	}
+
+	for k, ref := range r.SecretHeaders {
+		if k == "" {
+			return errInvalidSecretHeader
+		}
+		if err := secrets.validate(ref); err != nil {
+			return err
+		}
+	}
+
+	a := r.Auth
+	if a == nil {
+		return nil
+	}
+
+	secret := a.Token
+	switch a.Type {
+	case AuthBasic:
+		if a.Username == "" || a.Token != "" {
+			return errInvalidAuth
+		}
+		secret = a.Password
+	case AuthBearer:
+		if a.Username != "" || a.Password != "" {
+			return errInvalidAuth
+		}
+	default:
+		return errInvalidAuth
+	}
+
+	if (secret == "") == (a.SecretRef == "") {
+		return errInvalidAuth
+	}
+	if a.SecretRef != "" {
+		return secrets.validate(a.SecretRef)
+	}
+	return nil
+}
+
+func (r *Request) method() string {
+	if r == nil || r.Method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(r.Method)
+}
+
+func (r *Request) body() string {
+	if r == nil {
+		return ""
+	}
+	return r.Body
+}
+
+// apply sets the headers and auth of the request on req, resolving the secret
+// ref of the auth with the secrets.
+func (r *Request) apply(req *http.Request, secrets *Secrets) error {
+	if r == nil {
+		return nil
+	}
+
+	for k, v := range r.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	for k, ref := range r.SecretHeaders {
+		v, err := secrets.resolve(ref)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(k, v)
+	}
+
+	a := r.Auth
+	if a == nil {
+		return nil
+	}
+
+	secret, err := a.secret(secrets)
+	if err != nil {
+		return err
+	}
+	switch a.Type {
+	case AuthBasic:
+		req.SetBasicAuth(a.Username, secret)
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+	return nil
+}
+
+func (a *Auth) secret(secrets *Secrets) (string, error) {
+	switch {
+	case a.SecretRef != "":
+		return secrets.resolve(a.SecretRef)
+	case a.Type == AuthBasic:
+		return a.Password, nil
+	}
+	return a.Token, nil
+}
+
+// hasSecrets reports whether the request holds any secrets inline, rather than
+// by reference.
+func (r *Request) hasSecrets() bool {
+	if r == nil {
+		return false
+	}
+	if a := r.Auth; a != nil && (a.Password != "" || a.Token != "") {
+		return true
+	}
+	for k := range r.Headers {
+		if !plainHeader(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact provides a copy of the request with the secrets it holds replaced.
+func (r *Request) redact() *Request {
+	if r == nil {
+		return nil
+	}
+
+	out := *r
+	if len(r.Headers) > 0 {
+		out.Headers = make(map[string]string, len(r.Headers))
+		for k, v := range r.Headers {
+			if !plainHeader(k) {
+				v = redacted
+			}
+			out.Headers[k] = v
+		}
+	}
+	if r.Auth != nil {
+		auth := *r.Auth
+		if auth.Password != "" {
+			auth.Password = redacted
+		}
+		if auth.Token != "" {
+			auth.Token = redacted
+		}
+		out.Auth = &auth
+	}
+	return &out
+}
+
+// plainHeaders are the headers whose values are echoed back, the values of
+// every other header are treated as secrets, as there is no telling which of
+// them carry credentials.
+var plainHeaders = map[string]bool{
+	"Accept":          true,
+	"Accept-Encoding": true,
+	"Accept-Language": true,
+	"Cache-Control":   true,
+	"Content-Type":    true,
+	"Host":            true,
+	"Origin":          true,
+	"User-Agent":      true,
+}
+
+func plainHeader(name string) bool {
+	return plainHeaders[http.CanonicalHeaderKey(name)]
+}
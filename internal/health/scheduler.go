@@ -0,0 +1,157 @@
+package health
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+type Scheduler struct {
+	repo Repository
+	svc  SVC
+	pool *Pool
+	tick time.Duration
+
+	// next holds the time each check is due to be probed. It is
+	// only accessed from the scheduling loop.
+	next map[string]time.Time
+	rand *rand.Rand
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler that probes checks according to their
+// interval and jitter, executing the probes on the provided pool. The tick
+// is the resolution the schedule is evaluated at.
+func NewScheduler(repo Repository, svc SVC, pool *Pool, tick time.Duration) *Scheduler {
+	return &Scheduler{
+		repo: repo,
+		svc:  svc,
+		pool: pool,
+		tick: tick,
+		next: make(map[string]time.Time),
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.loop(ctx)
+	}()
+}
+
+// Stop halts the scheduler and waits for the in flight probes to finish.
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+	s.pool.Close()
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	for {
+		s.schedule(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) schedule(ctx context.Context, now time.Time) {
+	_, checks := s.repo.List(0, -1)
+
+	var dropped int
+	seen := make(map[string]bool, len(checks))
+	for _, c := range checks {
+		seen[c.ID] = true
+		if c.State == StatePaused {
+			// forgotten so a resumed check is spread out over its jitter
+			delete(s.next, c.ID)
+			continue
+		}
+
+		next, ok := s.next[c.ID]
+		if !ok {
+			// newly discovered checks are spread out over their jitter
+			// so a batch of checks does not fire in the same instant.
+			next = now.Add(s.jitter(c))
+			s.next[c.ID] = next
+		}
+		if now.Before(next) {
+			continue
+		}
+
+		s.next[c.ID] = now.Add(checkInterval(c) + s.jitter(c))
+		// a check whose previous probe is still pending is skipped, only
+		// the probes dropped for the queue being full signal saturation
+		if err := s.submit(ctx, c); err == errQueueFull {
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		log.Printf("probe pool saturated: %d checks dropped", dropped)
+	}
+
+	for id := range s.next {
+		if !seen[id] {
+			delete(s.next, id)
+		}
+	}
+}
+
+func (s *Scheduler) submit(ctx context.Context, c Check) error {
+	id := c.ID
+	return s.pool.Submit(id, checkHost(c), func() {
+		_, err := s.svc.Run(ctx, id)
+		if err != nil && err != errCheckNotFound && err != errCheckPaused && ctx.Err() == nil {
+			log.Printf("failed to probe check %s: %s", id, err)
+		}
+	})
+}
+
+func (s *Scheduler) jitter(c Check) time.Duration {
+	if c.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(s.rand.Int63n(int64(c.Jitter)))
+}
+
+func checkHost(c Check) string {
+	switch c.Type {
+	case TypeTCP, TypeGRPC:
+		if host, _, err := net.SplitHostPort(c.Endpoint); err == nil {
+			return host
+		}
+		return c.Endpoint
+	case TypeDNS:
+		// the resolver, rather than the name resolved, bears the load
+		if addr := c.DNS.resolver(); addr != "" {
+			return addr
+		}
+		return "dns"
+	}
+
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return c.Endpoint
+	}
+	return u.Host
+}
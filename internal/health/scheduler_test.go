@@ -0,0 +1,148 @@
+package health_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestScheduler(t *testing.T) {
+	t.Run("probes every registered check", func(t *testing.T) {
+		repo := &fakeRepo{
+			listFn: func(page, size int) (int, []health.Check) {
+				return 2, []health.Check{
+					{ID: "id-1", Interval: health.Duration(10 * time.Millisecond)},
+					{ID: "id-2", Interval: health.Duration(10 * time.Millisecond)},
+				}
+			},
+		}
+
+		var (
+			mu  sync.Mutex
+			ran = make(map[string]int)
+		)
+		done := make(chan struct{})
+		svc := &fakeSVC{
+			runFn: func(ctx context.Context, id string) (health.Check, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				ran[id]++
+				if ran["id-1"] == 2 && ran["id-2"] == 2 {
+					close(done)
+				}
+				return health.Check{ID: id}, nil
+			},
+		}
+
+		scheduler := health.NewScheduler(repo, svc, health.NewPool(4, 4, 100), 10*time.Millisecond)
+		scheduler.Start()
+		defer scheduler.Stop()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for checks to be probed")
+		}
+	})
+
+	t.Run("probes checks according to their interval", func(t *testing.T) {
+		repo := &fakeRepo{
+			listFn: func(page, size int) (int, []health.Check) {
+				return 2, []health.Check{
+					{ID: "fast", Interval: health.Duration(time.Millisecond), Jitter: health.Duration(time.Microsecond)},
+					{ID: "slow", Interval: health.Duration(time.Hour), Jitter: health.Duration(time.Microsecond)},
+				}
+			},
+		}
+
+		var (
+			mu  sync.Mutex
+			ran = make(map[string]int)
+		)
+		svc := &fakeSVC{
+			runFn: func(ctx context.Context, id string) (health.Check, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				ran[id]++
+				return health.Check{ID: id}, nil
+			},
+		}
+
+		scheduler := health.NewScheduler(repo, svc, health.NewPool(4, 4, 100), time.Millisecond)
+		scheduler.Start()
+		time.Sleep(100 * time.Millisecond)
+		scheduler.Stop()
+
+		mu.Lock()
+		defer mu.Unlock()
+		if ran["fast"] < 5 {
+			t.Errorf("fast check probed too few times: got=%d", ran["fast"])
+		}
+		equal(t, 1, ran["slow"], "slow check probed unexpected number of times")
+	})
+
+	t.Run("skips paused checks", func(t *testing.T) {
+		repo := &fakeRepo{
+			listFn: func(page, size int) (int, []health.Check) {
+				return 2, []health.Check{
+					{ID: "active", Interval: health.Duration(time.Millisecond)},
+					{ID: "paused", Interval: health.Duration(time.Millisecond), State: health.StatePaused},
+				}
+			},
+		}
+
+		var (
+			mu  sync.Mutex
+			ran = make(map[string]int)
+		)
+		svc := &fakeSVC{
+			runFn: func(ctx context.Context, id string) (health.Check, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				ran[id]++
+				return health.Check{ID: id}, nil
+			},
+		}
+
+		scheduler := health.NewScheduler(repo, svc, health.NewPool(4, 4, 100), time.Millisecond)
+		scheduler.Start()
+		time.Sleep(50 * time.Millisecond)
+		scheduler.Stop()
+
+		mu.Lock()
+		defer mu.Unlock()
+		if ran["active"] == 0 {
+			t.Error("active check was not probed")
+		}
+		equal(t, 0, ran["paused"], "paused check was probed")
+	})
+
+	t.Run("stop waits for in flight probes", func(t *testing.T) {
+		repo := &fakeRepo{
+			listFn: func(page, size int) (int, []health.Check) {
+				return 1, []health.Check{{ID: "id-1"}}
+			},
+		}
+
+		started := make(chan struct{})
+		var finished bool
+		svc := &fakeSVC{
+			runFn: func(ctx context.Context, id string) (health.Check, error) {
+				close(started)
+				<-ctx.Done()
+				finished = true
+				return health.Check{}, ctx.Err()
+			},
+		}
+
+		scheduler := health.NewScheduler(repo, svc, health.NewPool(4, 4, 100), time.Hour)
+		scheduler.Start()
+		<-started
+		scheduler.Stop()
+
+		equal(t, true, finished, "in flight probe did not finish before stop returned")
+	})
+}
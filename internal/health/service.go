@@ -1,73 +1,367 @@
 package health
 
 import (
+	"context"
 	"crypto/md5"
 	"errors"
 	"fmt"
+	"log"
 	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// State is the health of a check as determined by its probes.
+type State string
+
+const (
+	// StatePending is the state of a check that has yet to reach a threshold.
+	StatePending State = "pending"
+	StateUp      State = "up"
+	// StateDegraded is the state of a check that is up, but warrants
+	// attention, i.e. its certificate is nearing expiry.
+	StateDegraded State = "degraded"
+	StateDown     State = "down"
+	StatePaused   State = "paused"
+	// StateFlapping is the state of a check that changes state too often
+	// for its state to be of use, see Flap.
+	StateFlapping State = "flapping"
+	// StateUnknown is the state of a check whose endpoint cannot tell
+	// whether it is healthy.
+	StateUnknown State = "unknown"
+)
+
+const (
+	TypeHTTP      = "http"
+	TypeTCP       = "tcp"
+	TypeDNS       = "dns"
+	TypeGRPC      = "grpc"
+	TypeWebSocket = "websocket"
 )
 
 type Check struct {
-	ID       string `json:"id"`
-	Status   string `json:"status"`
+	ID string `json:"id"`
+	// Type is the kind of probe performed, an http check when it is not set.
+	Type     string `json:"type,omitempty"`
+	State    State  `json:"state"`
 	Code     int32  `json:"code"`
 	Endpoint string `json:"endpoint"`
 	Checked  int64  `json:"checked"`
 	Duration string `json:"duration"`
+	// Labels are the arbitrary key value pairs maintenance windows target
+	// the check by.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	Interval Duration `json:"interval"`
+	Timeout  Duration `json:"timeout"`
+	Jitter   Duration `json:"jitter"`
+
+	Request *Request     `json:"request,omitempty"`
+	Expect  *Expectation `json:"expect,omitempty"`
+	DNS     *DNSQuery    `json:"dns,omitempty"`
+	GRPC    *GRPCQuery   `json:"grpc,omitempty"`
+	// WebSocket configures the message exchanged with a websocket check.
+	WebSocket *WebSocketQuery `json:"websocket,omitempty"`
+	// CertWarningDays flips the check to a warning when the certificate of
+	// the endpoint expires within the number of days.
+	CertWarningDays int `json:"cert_warning_days,omitempty"`
+
+	// Retries is the number of times a failed probe is retried, waiting
+	// RetryBackoff, doubled with every retry, in between. Retries that
+	// cannot complete within the interval are not attempted.
+	Retries      int      `json:"retries,omitempty"`
+	RetryBackoff Duration `json:"retry_backoff,omitempty"`
+	// FailureThreshold and SuccessThreshold are the consecutive failures and
+	// successes required for the check to be marked down and back up.
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+	SuccessThreshold int `json:"success_threshold,omitempty"`
+
+	ConsecutiveFailures  int `json:"consecutive_failures"`
+	ConsecutiveSuccesses int `json:"consecutive_successes"`
+
+	// FlapLowThreshold and FlapHighThreshold are the percent state change
+	// a check stops and starts flapping at, defaulting to 25 and 50.
+	FlapLowThreshold  float64 `json:"flap_low_threshold,omitempty"`
+	FlapHighThreshold float64 `json:"flap_high_threshold,omitempty"`
+	Flap              Flap    `json:"flap"`
+
+	// Failure describes why the latest probe of the check failed.
+	Failure string   `json:"failure,omitempty"`
+	TLS     *TLSInfo `json:"tls,omitempty"`
+	// Maintenance is the id of the maintenance window the latest probe of
+	// the check fell in.
+	Maintenance string `json:"maintenance,omitempty"`
+}
+
+// Redacted provides a copy of the check that is safe to respond with, without
+// any of the secrets used to probe it.
+func (c Check) Redacted() Check {
+	c.Request = c.Request.redact()
+	return c
 }
 
 type SVC interface {
-	Create(endpoint string) (Check, error)
+	Create(check Check) (Check, error)
 	Read(id string) (Check, error)
 	List(page int) (total, currentPage int, checks []Check)
 	Delete(id string) error
+	Run(ctx context.Context, id string) (Check, error)
+	Results(id string, from, to time.Time, limit int) ([]Result, error)
+	Events(id string, from, to time.Time, limit int) ([]Event, error)
+	Pause(id string) (Check, error)
+	Resume(id string) (Check, error)
+	Uptime(id string, window time.Duration) (Uptime, error)
+	Stats(id string, window time.Duration) (LatencyStats, error)
+
+	CreateMaintenance(w MaintenanceWindow) (MaintenanceWindow, error)
+	ReadMaintenance(id string) (MaintenanceWindow, error)
+	ListMaintenance() ([]MaintenanceWindow, error)
+	DeleteMaintenance(id string) error
 }
 
 type Repository interface {
 	Create(check Check) error
 	List(page, size int) (total int, checks []Check)
 	Read(id string) (Check, error)
+	Update(check Check) error
 	Delete(id string) error
 }
 
 type service struct {
-	repo Repository
+	repo        Repository
+	history     HistoryRepository
+	maintenance MaintenanceRepository
+	prober      Prober
+	latency     *latencyRecorder
+	metrics     *probeMetrics
+	clock       Clock
+	notifier    Notifier
+	secrets     *Secrets
+
+	// mu serializes the read-modify-write of a check when recording
+	// the outcome of a probe, and the deletion of a check with it, so
+	// nothing of a deleted check is recorded after it is deleted.
+	mu sync.Mutex
 }
 
 var _ SVC = (*service)(nil)
 
-func NewSVC(repo Repository) SVC {
-	return &service{
-		repo: repo,
+type SVCOption func(*service)
+
+func WithProber(p Prober) SVCOption {
+	return func(s *service) {
+		s.prober = p
+	}
+}
+
+func WithHistory(h HistoryRepository) SVCOption {
+	return func(s *service) {
+		s.history = h
+	}
+}
+
+func WithMaintenance(m MaintenanceRepository) SVCOption {
+	return func(s *service) {
+		s.maintenance = m
+	}
+}
+
+// WithMetrics registers the probe metrics, and the gauges describing the latest
+// probe of every check, with the registry.
+func WithMetrics(reg prometheus.Registerer) SVCOption {
+	return func(s *service) {
+		s.metrics = newProbeMetrics(reg, s.repo)
+	}
+}
+
+// WithSecrets limits the secret refs of the checks created to the secrets,
+// which must be the secrets the prober resolves them with.
+func WithSecrets(secrets *Secrets) SVCOption {
+	return func(s *service) {
+		s.secrets = secrets
+	}
+}
+
+func WithClock(c Clock) SVCOption {
+	return func(s *service) {
+		s.clock = c
 	}
 }
 
+// WithNotifier notifies the notifier of the state transitions of checks.
+func WithNotifier(n Notifier) SVCOption {
+	return func(s *service) {
+		s.notifier = n
+	}
+}
+
+func NewSVC(repo Repository, opts ...SVCOption) SVC {
+	s := &service{
+		repo:        repo,
+		history:     NewMemoryHistoryRepository(defaultHistorySize),
+		maintenance: NewMemoryMaintenanceRepository(),
+		prober:      NewProber(nil, nil),
+		latency:     newLatencyRecorder(),
+		clock:       realClock{},
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+const (
+	defaultInterval = 30 * time.Second
+	defaultTimeout  = 10 * time.Second
+	minInterval     = time.Second
+
+	defaultHistorySize = 10000
+	defaultResultLimit = 100
+	maxResultLimit     = 1000
+
+	defaultUptimeWindow = 24 * time.Hour
+	maxUptimeWindow     = 30 * 24 * time.Hour
+	defaultStatsWindow  = time.Hour
+)
+
 var (
 	errInvalidEndpoint = errors.New("endpoint must be a valid absolute URL")
+	errInvalidType     = errors.New("type must be one of http, tcp, dns, grpc or websocket")
+	errInvalidInterval = errors.New("interval must be at least 1s")
+	errInvalidTimeout  = errors.New("timeout must be positive and no greater than the interval")
+	errInvalidJitter   = errors.New("jitter must be positive and less than the interval")
+	errInvalidLabels   = errors.New("labels must have non-empty keys of no more than 63 characters")
 )
 
-func (s *service) Create(endpoint string) (Check, error) {
-	u, err := validateURL(endpoint)
+func (s *service) Create(check Check) (Check, error) {
+	typ, endpoint, err := validateEndpoint(check)
 	if err != nil {
-		return Check{}, errInvalidEndpoint
+		return Check{}, err
 	}
 
-	id, err := newID(endpoint)
+	interval, timeout, jitter, err := validateConfig(check, s.secrets)
 	if err != nil {
-		return Check{}, errors.New("unexpected error")
+		return Check{}, err
 	}
 
 	newCheck := Check{
-		ID:       id,
-		Status:   "Created",
-		Endpoint: u.String(),
+		Type:     typ,
+		State:    StatePending,
+		Endpoint: endpoint,
+		Labels:   check.Labels,
+		Interval: Duration(interval),
+		Timeout:  Duration(timeout),
+		Jitter:   Duration(jitter),
+		Request:  check.Request,
+		Expect:   check.Expect,
+		DNS:      check.DNS,
+		GRPC:     check.GRPC,
+
+		WebSocket:       check.WebSocket,
+		CertWarningDays: check.CertWarningDays,
+
+		Retries:          check.Retries,
+		RetryBackoff:     check.RetryBackoff,
+		FailureThreshold: check.FailureThreshold,
+		SuccessThreshold: check.SuccessThreshold,
+
+		FlapLowThreshold:  check.FlapLowThreshold,
+		FlapHighThreshold: check.FlapHighThreshold,
+	}
+	newCheck.ID, err = newID(newCheck)
+	if err != nil {
+		return Check{}, errors.New("unexpected error")
 	}
+
 	if err := s.repo.Create(newCheck); err != nil {
 		return Check{}, err
 	}
+
+	err = s.history.AppendEvent(Event{
+		CheckID: newCheck.ID,
+		Time:    s.clock.Now(),
+		To:      newCheck.State,
+		Reason:  "check created",
+	})
+	if err != nil {
+		return Check{}, err
+	}
 	return newCheck, nil
 }
 
+// validateConfig validates everything of the check but its endpoint, providing
+// the schedule of the check.
+func validateConfig(check Check, secrets *Secrets) (interval, timeout, jitter time.Duration, err error) {
+	interval, timeout, jitter, err = validateSchedule(check)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	if err := validateTypeConfig(check); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := validateRequest(check.Request, secrets); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := validateExpectation(check.Expect); err != nil {
+		return 0, 0, 0, err
+	}
+	if check.CertWarningDays < 0 || check.CertWarningDays > maxCertWarningDays {
+		return 0, 0, 0, errInvalidCertWarningDays
+	}
+	if err := validatePolicy(check, interval); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := validateFlapThresholds(check); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := validateLabels(check.Labels); err != nil {
+		return 0, 0, 0, err
+	}
+	return interval, timeout, jitter, nil
+}
+
+var (
+	errUnappliedRequest     = errors.New("request applies to http and websocket checks only")
+	errUnappliedExpect      = errors.New("expect applies to http checks only")
+	errUnappliedCertWarning = errors.New("cert_warning_days applies to http and websocket checks only")
+	errUnappliedDNS         = errors.New("dns applies to dns checks only")
+	errUnappliedGRPC        = errors.New("grpc applies to grpc checks only")
+	errUnappliedWebSocket   = errors.New("websocket applies to websocket checks only")
+)
+
+// validateTypeConfig rejects the config the type of the check does not apply,
+// so that a check is not created to assert what its probe never checks.
+func validateTypeConfig(c Check) error {
+	typ := c.Type
+	if typ == "" {
+		typ = TypeHTTP
+	}
+	overHTTP := typ == TypeHTTP || typ == TypeWebSocket
+
+	switch {
+	case c.Request != nil && !overHTTP:
+		return errUnappliedRequest
+	case c.Expect != nil && typ != TypeHTTP:
+		return errUnappliedExpect
+	case c.CertWarningDays != 0 && !overHTTP:
+		return errUnappliedCertWarning
+	case c.DNS != nil && typ != TypeDNS:
+		return errUnappliedDNS
+	case c.GRPC != nil && typ != TypeGRPC:
+		return errUnappliedGRPC
+	case c.WebSocket != nil && typ != TypeWebSocket:
+		return errUnappliedWebSocket
+	}
+
+	if typ == TypeWebSocket {
+		return validateWebSocket(c)
+	}
+	return nil
+}
+
 func (s *service) List(page int) (int, int, []Check) {
 	if page <= 0 {
 		page = 1
@@ -89,7 +383,400 @@ func (s *service) Delete(id string) error {
 	if err := validID(id); err != nil {
 		return err
 	}
-	return s.repo.Delete(id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.latency.delete(id)
+	s.metrics.delete(id)
+	return s.history.DeleteHistory(id)
+}
+
+var (
+	errInvalidTimeRange = errors.New("from must be before to")
+	errInvalidLimit     = fmt.Errorf("limit must be between 1 and %d", maxResultLimit)
+)
+
+func (s *service) Results(id string, from, to time.Time, limit int) ([]Result, error) {
+	if err := validID(id); err != nil {
+		return nil, err
+	}
+	if to.IsZero() {
+		to = s.clock.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-time.Hour)
+	}
+	if from.After(to) {
+		return nil, errInvalidTimeRange
+	}
+	if limit == 0 {
+		limit = defaultResultLimit
+	}
+	if limit < 0 || limit > maxResultLimit {
+		return nil, errInvalidLimit
+	}
+
+	if _, err := s.repo.Read(id); err != nil {
+		return nil, err
+	}
+	return s.history.Results(id, from, to, limit)
+}
+
+func (s *service) Events(id string, from, to time.Time, limit int) ([]Event, error) {
+	if err := validID(id); err != nil {
+		return nil, err
+	}
+	if to.IsZero() {
+		to = s.clock.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultEventsWindow)
+	}
+	if from.After(to) {
+		return nil, errInvalidTimeRange
+	}
+	if limit == 0 {
+		limit = defaultResultLimit
+	}
+	if limit < 0 || limit > maxResultLimit {
+		return nil, errInvalidLimit
+	}
+
+	if _, err := s.repo.Read(id); err != nil {
+		return nil, err
+	}
+	return s.history.Events(id, from, to, limit)
+}
+
+var errInvalidWindow = errors.New("window must be positive and no greater than 30d")
+
+func (s *service) Uptime(id string, window time.Duration) (Uptime, error) {
+	if err := validID(id); err != nil {
+		return Uptime{}, err
+	}
+	if window == 0 {
+		window = defaultUptimeWindow
+	}
+	if window < 0 || window > maxUptimeWindow {
+		return Uptime{}, errInvalidWindow
+	}
+
+	if _, err := s.repo.Read(id); err != nil {
+		return Uptime{}, err
+	}
+
+	to := s.clock.Now()
+	from := to.Add(-window)
+	results, err := s.history.Results(id, from, to, 0)
+	if err != nil {
+		return Uptime{}, err
+	}
+
+	u := computeUptime(results, from, to)
+	u.CheckID = id
+	return u, nil
+}
+
+func (s *service) Stats(id string, window time.Duration) (LatencyStats, error) {
+	if err := validID(id); err != nil {
+		return LatencyStats{}, err
+	}
+	if window == 0 {
+		window = defaultStatsWindow
+	}
+	if window < 0 || window > maxUptimeWindow {
+		return LatencyStats{}, errInvalidWindow
+	}
+
+	if _, err := s.repo.Read(id); err != nil {
+		return LatencyStats{}, err
+	}
+	if err := s.seedLatency(id); err != nil {
+		return LatencyStats{}, err
+	}
+
+	to := s.clock.Now()
+	from := to.Add(-window)
+	stats := newLatencyStats(s.latency.sketch(id, from, to), from, to)
+	stats.CheckID = id
+	return stats, nil
+}
+
+// seedLatency loads the latencies of the results in the history of the check
+// the first time the check is seen, the latencies are only kept in memory. It
+// must complete before a result of the check is appended to the history,
+// otherwise the result would be recorded twice, and is never called with the
+// mu of the service held, as loading the history of a check is slow.
+func (s *service) seedLatency(id string) error {
+	return s.latency.seed(id, func() error {
+		to := s.clock.Now()
+		results, err := s.history.Results(id, to.Add(-hourSlotRetention), to, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, res := range results {
+			s.recordLatency(res)
+		}
+		return nil
+	})
+}
+
+func (s *service) recordLatency(res Result) {
+	// probes that failed to get a response say nothing of the latency
+	if res.Error != "" {
+		return
+	}
+	s.latency.record(res.CheckID, res.Checked, time.Duration(res.Duration))
+}
+
+var errCheckPaused = errors.New("check is paused")
+
+func (s *service) Run(ctx context.Context, id string) (Check, error) {
+	if err := validID(id); err != nil {
+		return Check{}, err
+	}
+
+	check, err := s.repo.Read(id)
+	if err != nil {
+		return Check{}, err
+	}
+	if check.State == StatePaused {
+		return Check{}, errCheckPaused
+	}
+
+	// the retries hold the worker the probe runs on, so they must finish
+	// within the interval, before the check is due to be probed again
+	deadline := s.clock.Now().Add(checkInterval(check))
+
+	res := s.probe(ctx, check)
+	for attempt := 0; !res.OK && attempt < check.Retries && ctx.Err() == nil; attempt++ {
+		backoff := retryBackoff(check, attempt)
+		if s.clock.Now().Add(backoff + checkTimeout(check)).After(deadline) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-s.clock.After(backoff):
+			res = s.probe(ctx, check)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		// a canceled probe says nothing about the health of the endpoint
+		return Check{}, err
+	}
+
+	if err := s.seedLatency(id); err != nil {
+		return Check{}, err
+	}
+	check, event, err := s.record(res)
+	if err != nil {
+		return Check{}, err
+	}
+	if event != nil && s.notifier != nil {
+		if err := s.notifier.Notify(ctx, check, *event); err != nil {
+			log.Printf("failed to notify of check %s transitioning to %s: %s", check.ID, event.To, err)
+		}
+	}
+	return check, nil
+}
+
+func (s *service) probe(ctx context.Context, check Check) Result {
+	probeCtx, cancel := context.WithTimeout(ctx, checkTimeout(check))
+	defer cancel()
+
+	return s.prober.Probe(probeCtx, check)
+}
+
+// Pause stops the check from being probed, retaining its configuration and
+// history. Pausing a paused check is a no-op.
+func (s *service) Pause(id string) (Check, error) {
+	return s.setPaused(id, true)
+}
+
+// Resume resumes probing the paused check, which is pending until the
+// thresholds are reached again. Resuming a check that is not paused is a
+// no-op.
+func (s *service) Resume(id string) (Check, error) {
+	return s.setPaused(id, false)
+}
+
+func (s *service) setPaused(id string, paused bool) (Check, error) {
+	if err := validID(id); err != nil {
+		return Check{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	check, err := s.repo.Read(id)
+	if err != nil {
+		return Check{}, err
+	}
+	if (check.State == StatePaused) == paused {
+		return check, nil
+	}
+
+	prev := check.State
+	reason := "check paused"
+	check.State = StatePaused
+	if !paused {
+		reason = "check resumed"
+		check.State = StatePending
+		check.ConsecutiveFailures, check.ConsecutiveSuccesses = 0, 0
+		check.Flap = Flap{}
+	}
+
+	if err := s.repo.Update(check); err != nil {
+		return Check{}, err
+	}
+	err = s.history.AppendEvent(Event{
+		CheckID: check.ID,
+		Time:    s.clock.Now(),
+		From:    prev,
+		To:      check.State,
+		Reason:  reason,
+	})
+	if err != nil {
+		return Check{}, err
+	}
+	return check, nil
+}
+
+// record applies the result to the check, providing the event of the
+// transition when the state of the check changed.
+func (s *service) record(res Result) (Check, *Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the check is read again as it may have changed, or been deleted,
+	// while the probe was in flight.
+	check, err := s.repo.Read(res.CheckID)
+	if err != nil {
+		return Check{}, nil, err
+	}
+
+	if check.State == StatePaused {
+		// the check was paused while the probe was in flight
+		return Check{}, nil, errCheckPaused
+	}
+	if check.State == "" {
+		// checks persisted before the state was typed
+		check.State = StatePending
+	}
+	prev := check.State
+
+	windows, err := s.maintenance.ListWindows()
+	if err != nil {
+		return Check{}, nil, err
+	}
+	check.Maintenance = activeMaintenance(windows, check, res.Checked)
+	res.Maintenance = check.Maintenance
+
+	check.Code = res.Code
+	check.Checked = res.Checked.Unix()
+	check.Duration = time.Duration(res.Duration).Round(time.Microsecond).String()
+	check.Failure = res.Error
+	if res.Failure != "" {
+		check.Failure = res.Assertion + ": " + res.Failure
+	}
+	check.TLS = res.TLS
+
+	// probes during maintenance are recorded, but leave the state of the
+	// check as it was.
+	if check.Maintenance == "" {
+		// the thresholds apply to the settled state of a flapping check
+		check.State = settledState(check)
+		applyResult(&check, res)
+		if check.State == StateUp && res.OK && res.TLS != nil && res.TLS.DaysRemaining < check.CertWarningDays {
+			check.State = StateDegraded
+			check.Failure = fmt.Sprintf("tls: certificate expires in %d days", res.TLS.DaysRemaining)
+		}
+		detectFlapping(&check, res.OK, prev == StateFlapping)
+	}
+
+	if err := s.repo.Update(check); err != nil {
+		return Check{}, nil, err
+	}
+	if err := s.history.AppendResult(res); err != nil {
+		return Check{}, nil, err
+	}
+
+	var event *Event
+	if check.State != prev {
+		event = &Event{
+			CheckID: check.ID,
+			Time:    res.Checked,
+			From:    prev,
+			To:      check.State,
+			Reason:  transitionReason(prev, check),
+		}
+		if err := s.history.AppendEvent(*event); err != nil {
+			return Check{}, nil, err
+		}
+	}
+	s.recordLatency(res)
+	s.metrics.observe(check, res)
+	return check, event, nil
+}
+
+var errInvalidMaintenanceID = errors.New("invalid maintenance window id provided")
+
+func (s *service) CreateMaintenance(w MaintenanceWindow) (MaintenanceWindow, error) {
+	if err := validateMaintenanceWindow(&w); err != nil {
+		return MaintenanceWindow{}, err
+	}
+
+	id, err := newMaintenanceID()
+	if err != nil {
+		return MaintenanceWindow{}, errors.New("unexpected error")
+	}
+	w.ID = id
+	w.Active = false
+
+	if err := s.maintenance.CreateWindow(w); err != nil {
+		return MaintenanceWindow{}, err
+	}
+	w.Active = w.active(s.clock.Now())
+	return w, nil
+}
+
+func (s *service) ReadMaintenance(id string) (MaintenanceWindow, error) {
+	if len(id) != 32 {
+		return MaintenanceWindow{}, errInvalidMaintenanceID
+	}
+
+	w, err := s.maintenance.ReadWindow(id)
+	if err != nil {
+		return MaintenanceWindow{}, err
+	}
+	w.Active = w.active(s.clock.Now())
+	return w, nil
+}
+
+func (s *service) ListMaintenance() ([]MaintenanceWindow, error) {
+	windows, err := s.maintenance.ListWindows()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	for i := range windows {
+		windows[i].Active = windows[i].active(now)
+	}
+	return windows, nil
+}
+
+func (s *service) DeleteMaintenance(id string) error {
+	if len(id) != 32 {
+		return errInvalidMaintenanceID
+	}
+	return s.maintenance.DeleteWindow(id)
 }
 
 func validID(id string) error {
@@ -99,6 +786,87 @@ func validID(id string) error {
 	return nil
 }
 
+func validateSchedule(c Check) (interval, timeout, jitter time.Duration, err error) {
+	interval, timeout, jitter = time.Duration(c.Interval), time.Duration(c.Timeout), time.Duration(c.Jitter)
+	if interval == 0 {
+		interval = defaultInterval
+	}
+	if timeout == 0 {
+		timeout = defaultTimeout
+		if timeout > interval {
+			timeout = interval
+		}
+	}
+
+	switch {
+	case interval < minInterval:
+		return 0, 0, 0, errInvalidInterval
+	case timeout < 0 || timeout > interval:
+		return 0, 0, 0, errInvalidTimeout
+	case jitter < 0 || jitter >= interval:
+		return 0, 0, 0, errInvalidJitter
+	}
+	return interval, timeout, jitter, nil
+}
+
+func validateLabels(labels map[string]string) error {
+	for k := range labels {
+		if k == "" || len(k) > 63 {
+			return errInvalidLabels
+		}
+	}
+	return nil
+}
+
+// checkInterval and checkTimeout provide the defaults for checks that were
+// persisted before the schedule was configurable per check.
+func checkInterval(c Check) time.Duration {
+	if c.Interval <= 0 {
+		return defaultInterval
+	}
+	return time.Duration(c.Interval)
+}
+
+func checkTimeout(c Check) time.Duration {
+	if c.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return time.Duration(c.Timeout)
+}
+
+// validateEndpoint validates the endpoint of the check according to its type,
+// providing the type and endpoint in their normalized form.
+func validateEndpoint(c Check) (string, string, error) {
+	switch c.Type {
+	case "", TypeHTTP:
+		u, err := validateURL(c.Endpoint)
+		if err != nil {
+			return "", "", errInvalidEndpoint
+		}
+		return TypeHTTP, u.String(), nil
+	case TypeWebSocket:
+		u, err := validateURL(c.Endpoint)
+		if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") {
+			return "", "", errInvalidWebSocketEndpoint
+		}
+		return TypeWebSocket, u.String(), nil
+	case TypeTCP, TypeGRPC:
+		if err := validateHostPort(c.Endpoint); err != nil {
+			return "", "", err
+		}
+		return c.Type, c.Endpoint, nil
+	case TypeDNS:
+		if err := validateDNSName(c.Endpoint); err != nil {
+			return "", "", err
+		}
+		if err := validateDNSQuery(c.DNS); err != nil {
+			return "", "", err
+		}
+		return TypeDNS, c.Endpoint, nil
+	}
+	return "", "", errInvalidType
+}
+
 func validateURL(endpoint string) (*url.URL, error) {
 	if endpoint == "" {
 		return nil, errInvalidEndpoint
@@ -114,9 +882,32 @@ func validateURL(endpoint string) (*url.URL, error) {
 	return u, nil
 }
 
-func newID(endpoint string) (string, error) {
+// newID provides the id of the check from its type and normalized endpoint,
+// along with the service of a grpc check, and the record type and resolver of
+// a dns check, as they check apart what the endpoint serves. The type is left
+// out for http checks, keeping the ids of the checks created before there
+// were other types.
+func newID(c Check) (string, error) {
+	key := c.Endpoint
+	if c.Type != TypeHTTP {
+		// a space is valid in neither a URL nor a host
+		key = c.Type + " " + c.Endpoint
+	}
+	if c.Type == TypeGRPC && c.GRPC != nil && c.GRPC.Service != "" {
+		key += " service=" + c.GRPC.Service
+	}
+	if c.Type == TypeDNS {
+		// an A record resolved by the system resolver is the default query
+		if typ := c.DNS.recordType(); typ != "A" {
+			key += " record_type=" + typ
+		}
+		if resolver := c.DNS.resolver(); resolver != "" {
+			key += " resolver=" + resolver
+		}
+	}
+
 	h := md5.New()
-	_, err := h.Write([]byte(endpoint))
+	_, err := h.Write([]byte(key))
 	if err != nil {
 		return "", err
 	}
@@ -1,13 +1,20 @@
 package health_test
 
 import (
+	"context"
 	"crypto/md5"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jsteenb2/health/internal/health"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func TestService(t *testing.T) {
@@ -36,14 +43,140 @@ func TestService(t *testing.T) {
 			svc := health.NewSVC(repo)
 
 			endpoint := "http://www.example.com"
-			c, err := svc.Create(endpoint)
+			c, err := svc.Create(health.Check{Endpoint: endpoint})
 			mustNoError(t, err)
 
 			equal(t, endpoint, c.Endpoint, "invalid endpoint")
-			equal(t, "Created", c.Status, "invalid status")
+			equal(t, health.StatePending, c.State, "invalid state")
+			equal(t, health.Duration(30*time.Second), c.Interval, "invalid default interval")
+			equal(t, health.Duration(10*time.Second), c.Timeout, "invalid default timeout")
+			equal(t, health.Duration(0), c.Jitter, "invalid default jitter")
 			validateID(t, endpoint, c.ID)
 		})
 
+		t.Run("ids", func(t *testing.T) {
+			svc := health.NewSVC(health.NewMemoryRepository())
+
+			http, err := svc.Create(health.Check{Endpoint: "HTTP://www.example.com"})
+			mustNoError(t, err)
+			validateID(t, "http://www.example.com", http.ID)
+
+			_, err = svc.Create(health.Check{Endpoint: "http://www.example.com"})
+			mustError(t, err)
+
+			tcp, err := svc.Create(health.Check{Type: health.TypeTCP, Endpoint: "www.example.com:443"})
+			mustNoError(t, err)
+			validateID(t, "tcp www.example.com:443", tcp.ID)
+
+			grpc, err := svc.Create(health.Check{Type: health.TypeGRPC, Endpoint: "www.example.com:443"})
+			mustNoError(t, err)
+			validateID(t, "grpc www.example.com:443", grpc.ID)
+
+			for _, service := range []string{"payments", "ledger"} {
+				c, err := svc.Create(health.Check{Type: health.TypeGRPC, Endpoint: "www.example.com:443", GRPC: &health.GRPCQuery{Service: service}})
+				mustNoError(t, err)
+				validateID(t, "grpc www.example.com:443 service="+service, c.ID)
+			}
+
+			dns := []struct {
+				query *health.DNSQuery
+				key   string
+			}{
+				{query: nil, key: "dns example.com"},
+				{query: &health.DNSQuery{RecordType: "mx"}, key: "dns example.com record_type=MX"},
+				{query: &health.DNSQuery{Resolver: "1.1.1.1"}, key: "dns example.com resolver=1.1.1.1:53"},
+				{query: &health.DNSQuery{Resolver: "8.8.8.8:53"}, key: "dns example.com resolver=8.8.8.8:53"},
+				{query: &health.DNSQuery{RecordType: "MX", Resolver: "8.8.8.8"}, key: "dns example.com record_type=MX resolver=8.8.8.8:53"},
+			}
+			for _, q := range dns {
+				c, err := svc.Create(health.Check{Type: health.TypeDNS, Endpoint: "example.com", DNS: q.query})
+				mustNoError(t, err)
+				validateID(t, q.key, c.ID)
+			}
+
+			_, err = svc.Create(health.Check{Type: health.TypeDNS, Endpoint: "example.com", DNS: &health.DNSQuery{RecordType: "A"}})
+			mustError(t, err)
+		})
+
+		t.Run("schedule", func(t *testing.T) {
+			tests := []struct {
+				name          string
+				check         health.Check
+				shouldErr     bool
+				expectedCheck health.Check
+			}{
+				{
+					name: "provided schedule is kept",
+					check: health.Check{
+						Interval: health.Duration(10 * time.Minute),
+						Timeout:  health.Duration(30 * time.Second),
+						Jitter:   health.Duration(time.Minute),
+					},
+					expectedCheck: health.Check{
+						Interval: health.Duration(10 * time.Minute),
+						Timeout:  health.Duration(30 * time.Second),
+						Jitter:   health.Duration(time.Minute),
+					},
+				},
+				{
+					name:  "default timeout is capped at the interval",
+					check: health.Check{Interval: health.Duration(5 * time.Second)},
+					expectedCheck: health.Check{
+						Interval: health.Duration(5 * time.Second),
+						Timeout:  health.Duration(5 * time.Second),
+					},
+				},
+				{
+					name:      "interval less than 1s",
+					check:     health.Check{Interval: health.Duration(time.Millisecond)},
+					shouldErr: true,
+				},
+				{
+					name:      "timeout greater than interval",
+					check:     health.Check{Interval: health.Duration(time.Second), Timeout: health.Duration(2 * time.Second)},
+					shouldErr: true,
+				},
+				{
+					name:      "negative timeout",
+					check:     health.Check{Timeout: health.Duration(-time.Second)},
+					shouldErr: true,
+				},
+				{
+					name:      "jitter equal to interval",
+					check:     health.Check{Interval: health.Duration(time.Minute), Jitter: health.Duration(time.Minute)},
+					shouldErr: true,
+				},
+				{
+					name:      "negative jitter",
+					check:     health.Check{Jitter: health.Duration(-time.Second)},
+					shouldErr: true,
+				},
+			}
+
+			for _, tt := range tests {
+				fn := func(t *testing.T) {
+					repo := &fakeRepo{
+						createFn: func(check health.Check) error { return nil },
+					}
+					svc := health.NewSVC(repo)
+
+					tt.check.Endpoint = "http://example.com"
+					c, err := svc.Create(tt.check)
+					if tt.shouldErr {
+						mustError(t, err)
+						return
+					}
+					mustNoError(t, err)
+
+					equal(t, tt.expectedCheck.Interval, c.Interval, "unexpected interval")
+					equal(t, tt.expectedCheck.Timeout, c.Timeout, "unexpected timeout")
+					equal(t, tt.expectedCheck.Jitter, c.Jitter, "unexpected jitter")
+				}
+
+				t.Run(tt.name, fn)
+			}
+		})
+
 		t.Run("invalid urls ", func(t *testing.T) {
 			tests := []struct {
 				name     string
@@ -62,7 +195,7 @@ func TestService(t *testing.T) {
 					}
 					svc := health.NewSVC(repo)
 
-					_, err := svc.Create(tt.endpoint)
+					_, err := svc.Create(health.Check{Endpoint: tt.endpoint})
 					mustError(t, err)
 				}
 
@@ -70,6 +203,325 @@ func TestService(t *testing.T) {
 			}
 		})
 
+		t.Run("expectations", func(t *testing.T) {
+			tests := []struct {
+				name      string
+				expect    *health.Expectation
+				shouldErr bool
+			}{
+				{name: "valid", expect: &health.Expectation{
+					Codes:     "200-204, 304",
+					Headers:   map[string]string{"Content-Type": "application/json"},
+					Body:      "ok",
+					BodyRegex: `"status":\s*"ok"`,
+					JSONPath:  "$.checks[0].status",
+					JSONValue: "ok",
+				}},
+				{name: "invalid code", expect: &health.Expectation{Codes: "2xx"}, shouldErr: true},
+				{name: "code out of range", expect: &health.Expectation{Codes: "200-700"}, shouldErr: true},
+				{name: "inverted code range", expect: &health.Expectation{Codes: "299-200"}, shouldErr: true},
+				{name: "invalid body regex", expect: &health.Expectation{BodyRegex: "(unclosed"}, shouldErr: true},
+				{name: "invalid json path", expect: &health.Expectation{JSONPath: "status"}, shouldErr: true},
+				{name: "invalid json path index", expect: &health.Expectation{JSONPath: "$.checks[a]"}, shouldErr: true},
+				{name: "json value without path", expect: &health.Expectation{JSONValue: "ok"}, shouldErr: true},
+			}
+
+			for _, tt := range tests {
+				fn := func(t *testing.T) {
+					repo := &fakeRepo{
+						createFn: func(check health.Check) error { return nil },
+					}
+					svc := health.NewSVC(repo)
+
+					c, err := svc.Create(health.Check{Endpoint: "http://example.com", Expect: tt.expect})
+					if tt.shouldErr {
+						mustError(t, err)
+						return
+					}
+					mustNoError(t, err)
+					equal(t, tt.expect, c.Expect, "expectation not kept")
+				}
+
+				t.Run(tt.name, fn)
+			}
+		})
+
+		t.Run("requests", func(t *testing.T) {
+			tests := []struct {
+				name      string
+				request   *health.Request
+				shouldErr bool
+			}{
+				{name: "method and body", request: &health.Request{Method: "POST", Body: "{}"}},
+				{name: "basic auth", request: &health.Request{Auth: &health.Auth{Type: health.AuthBasic, Username: "user", Password: "pass"}}},
+				{name: "basic auth secret ref", request: &health.Request{Auth: &health.Auth{Type: health.AuthBasic, Username: "user", SecretRef: "env:HEALTH_SECRET_PASSWORD"}}},
+				{name: "bearer token", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, Token: "token"}}},
+				{name: "bearer secret ref", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "file:/run/secrets/token"}}},
+				{name: "bearer secret ref relative to the secrets dir", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "file:token"}}},
+				{name: "secret header", request: &health.Request{SecretHeaders: map[string]string{"X-Auth": "env:HEALTH_SECRET_AUTH"}}},
+				{name: "invalid method", request: &health.Request{Method: "CONNECT"}, shouldErr: true},
+				{name: "secret header without a name", request: &health.Request{SecretHeaders: map[string]string{"": "env:HEALTH_SECRET_AUTH"}}, shouldErr: true},
+				{name: "secret header with an invalid secret ref", request: &health.Request{SecretHeaders: map[string]string{"X-Auth": "s3cr3t"}}, shouldErr: true},
+				{name: "unknown auth type", request: &health.Request{Auth: &health.Auth{Type: "digest", Token: "token"}}, shouldErr: true},
+				{name: "basic auth without username", request: &health.Request{Auth: &health.Auth{Type: health.AuthBasic, Password: "pass"}}, shouldErr: true},
+				{name: "basic auth without password", request: &health.Request{Auth: &health.Auth{Type: health.AuthBasic, Username: "user"}}, shouldErr: true},
+				{name: "bearer without token", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer}}, shouldErr: true},
+				{name: "token and secret ref", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, Token: "token", SecretRef: "env:TOKEN"}}, shouldErr: true},
+				{name: "invalid secret ref", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "vault:token"}}, shouldErr: true},
+				{name: "secret ref env var without the prefix", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "env:AWS_SECRET_ACCESS_KEY"}}, shouldErr: true},
+				{name: "secret ref of the env var prefix", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "env:HEALTH_SECRET_"}}, shouldErr: true},
+				{name: "secret ref file outside the secrets dir", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "file:/etc/passwd"}}, shouldErr: true},
+				{name: "secret ref file traversing out of the secrets dir", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "file:../../etc/passwd"}}, shouldErr: true},
+				{name: "secret ref of the secrets dir", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "file:/run/secrets"}}, shouldErr: true},
+			}
+
+			for _, tt := range tests {
+				fn := func(t *testing.T) {
+					repo := &fakeRepo{
+						createFn: func(check health.Check) error { return nil },
+					}
+					svc := health.NewSVC(repo, health.WithSecrets(health.NewSecrets("/run/secrets")))
+
+					c, err := svc.Create(health.Check{Endpoint: "http://example.com", Request: tt.request})
+					if tt.shouldErr {
+						mustError(t, err)
+						return
+					}
+					mustNoError(t, err)
+					equal(t, tt.request, c.Request, "request not kept")
+				}
+
+				t.Run(tt.name, fn)
+			}
+		})
+
+		t.Run("types", func(t *testing.T) {
+			tests := []struct {
+				name             string
+				check            health.Check
+				expectedType     string
+				expectedEndpoint string
+				shouldErr        bool
+			}{
+				{
+					name:             "http by default",
+					check:            health.Check{Endpoint: "http://example.com"},
+					expectedType:     health.TypeHTTP,
+					expectedEndpoint: "http://example.com",
+				},
+				{
+					name:             "tcp",
+					check:            health.Check{Type: health.TypeTCP, Endpoint: "db.example.com:5432"},
+					expectedType:     health.TypeTCP,
+					expectedEndpoint: "db.example.com:5432",
+				},
+				{
+					name: "dns",
+					check: health.Check{
+						Type:     health.TypeDNS,
+						Endpoint: "example.com",
+						DNS:      &health.DNSQuery{Resolver: "10.0.0.53", RecordType: "mx", Records: []string{"mail.example.com"}},
+					},
+					expectedType:     health.TypeDNS,
+					expectedEndpoint: "example.com",
+				},
+				{
+					name:             "grpc",
+					check:            health.Check{Type: health.TypeGRPC, Endpoint: "payments.internal:9090", GRPC: &health.GRPCQuery{Service: "payments"}},
+					expectedType:     health.TypeGRPC,
+					expectedEndpoint: "payments.internal:9090",
+				},
+				{name: "unknown type", check: health.Check{Type: "icmp", Endpoint: "example.com"}, shouldErr: true},
+				{
+					name:             "websocket",
+					check:            health.Check{Type: health.TypeWebSocket, Endpoint: "wss://gateway.example.com/ws", WebSocket: &health.WebSocketQuery{Message: "ping", Reply: "pong"}},
+					expectedType:     health.TypeWebSocket,
+					expectedEndpoint: "wss://gateway.example.com/ws",
+				},
+				{name: "websocket with http scheme", check: health.Check{Type: health.TypeWebSocket, Endpoint: "https://gateway.example.com/ws"}, shouldErr: true},
+				{name: "grpc without port", check: health.Check{Type: health.TypeGRPC, Endpoint: "payments.internal"}, shouldErr: true},
+				{name: "tcp without port", check: health.Check{Type: health.TypeTCP, Endpoint: "db.example.com"}, shouldErr: true},
+				{name: "tcp with invalid port", check: health.Check{Type: health.TypeTCP, Endpoint: "db.example.com:99999"}, shouldErr: true},
+				{name: "tcp url", check: health.Check{Type: health.TypeTCP, Endpoint: "tcp://db.example.com:5432"}, shouldErr: true},
+				{name: "dns url", check: health.Check{Type: health.TypeDNS, Endpoint: "http://example.com"}, shouldErr: true},
+				{
+					name:      "dns with invalid record type",
+					check:     health.Check{Type: health.TypeDNS, Endpoint: "example.com", DNS: &health.DNSQuery{RecordType: "SOA"}},
+					shouldErr: true,
+				},
+				{
+					name:      "dns with invalid resolver",
+					check:     health.Check{Type: health.TypeDNS, Endpoint: "example.com", DNS: &health.DNSQuery{Resolver: "10.0.0.53:dns"}},
+					shouldErr: true,
+				},
+				{
+					name:             "websocket with handshake headers",
+					check:            health.Check{Type: health.TypeWebSocket, Endpoint: "wss://gateway.example.com/ws", Request: &health.Request{Headers: map[string]string{"Host": "gateway.internal"}}},
+					expectedType:     health.TypeWebSocket,
+					expectedEndpoint: "wss://gateway.example.com/ws",
+				},
+				{
+					name:      "websocket reply without message",
+					check:     health.Check{Type: health.TypeWebSocket, Endpoint: "wss://gateway.example.com/ws", WebSocket: &health.WebSocketQuery{Reply: "pong"}},
+					shouldErr: true,
+				},
+				{
+					name:      "websocket with request body",
+					check:     health.Check{Type: health.TypeWebSocket, Endpoint: "wss://gateway.example.com/ws", Request: &health.Request{Body: "ping"}},
+					shouldErr: true,
+				},
+				{
+					name:      "websocket with request method",
+					check:     health.Check{Type: health.TypeWebSocket, Endpoint: "wss://gateway.example.com/ws", Request: &health.Request{Method: http.MethodPost}},
+					shouldErr: true,
+				},
+				{
+					name:      "websocket with expectation",
+					check:     health.Check{Type: health.TypeWebSocket, Endpoint: "wss://gateway.example.com/ws", Expect: &health.Expectation{Codes: "101"}},
+					shouldErr: true,
+				},
+				{
+					name:      "tcp with expectation",
+					check:     health.Check{Type: health.TypeTCP, Endpoint: "db.example.com:5432", Expect: &health.Expectation{Body: "ok"}},
+					shouldErr: true,
+				},
+				{
+					name:      "tcp with request",
+					check:     health.Check{Type: health.TypeTCP, Endpoint: "db.example.com:5432", Request: &health.Request{Body: "ping"}},
+					shouldErr: true,
+				},
+				{
+					name:      "dns with request",
+					check:     health.Check{Type: health.TypeDNS, Endpoint: "example.com", Request: &health.Request{Method: http.MethodGet}},
+					shouldErr: true,
+				},
+				{
+					name:      "grpc with expectation",
+					check:     health.Check{Type: health.TypeGRPC, Endpoint: "payments.internal:9090", Expect: &health.Expectation{Codes: "200"}},
+					shouldErr: true,
+				},
+				{
+					name:      "grpc with cert warning",
+					check:     health.Check{Type: health.TypeGRPC, Endpoint: "payments.internal:9090", CertWarningDays: 14},
+					shouldErr: true,
+				},
+				{
+					name:      "http with dns query",
+					check:     health.Check{Endpoint: "http://example.com", DNS: &health.DNSQuery{RecordType: "MX"}},
+					shouldErr: true,
+				},
+				{
+					name:      "tcp with grpc query",
+					check:     health.Check{Type: health.TypeTCP, Endpoint: "db.example.com:5432", GRPC: &health.GRPCQuery{Service: "db"}},
+					shouldErr: true,
+				},
+				{
+					name:      "http with websocket query",
+					check:     health.Check{Endpoint: "http://example.com", WebSocket: &health.WebSocketQuery{Message: "ping"}},
+					shouldErr: true,
+				},
+			}
+
+			for _, tt := range tests {
+				fn := func(t *testing.T) {
+					repo := &fakeRepo{
+						createFn: func(check health.Check) error { return nil },
+					}
+					svc := health.NewSVC(repo)
+
+					c, err := svc.Create(tt.check)
+					if tt.shouldErr {
+						mustError(t, err)
+						return
+					}
+					mustNoError(t, err)
+					equal(t, tt.expectedType, c.Type, "unexpected type")
+					equal(t, tt.expectedEndpoint, c.Endpoint, "unexpected endpoint")
+					equal(t, tt.check.DNS, c.DNS, "dns query not kept")
+					equal(t, tt.check.WebSocket, c.WebSocket, "websocket query not kept")
+				}
+
+				t.Run(tt.name, fn)
+			}
+		})
+
+		t.Run("policy", func(t *testing.T) {
+			tests := []struct {
+				name      string
+				check     health.Check
+				shouldErr bool
+			}{
+				{name: "retries and thresholds", check: health.Check{Retries: 2, RetryBackoff: health.Duration(time.Second), FailureThreshold: 3, SuccessThreshold: 2}},
+				{name: "too many retries", check: health.Check{Retries: 11}, shouldErr: true},
+				{name: "negative retries", check: health.Check{Retries: -1}, shouldErr: true},
+				{name: "backoff longer than the interval", check: health.Check{Retries: 1, RetryBackoff: health.Duration(time.Minute)}, shouldErr: true},
+				{name: "negative failure threshold", check: health.Check{FailureThreshold: -1}, shouldErr: true},
+				{name: "success threshold too large", check: health.Check{SuccessThreshold: 101}, shouldErr: true},
+				{name: "flap thresholds", check: health.Check{FlapLowThreshold: 10, FlapHighThreshold: 30}},
+				{name: "flap low threshold above the high threshold", check: health.Check{FlapLowThreshold: 60}, shouldErr: true},
+				{name: "flap high threshold above 100", check: health.Check{FlapHighThreshold: 101}, shouldErr: true},
+				{name: "negative flap threshold", check: health.Check{FlapLowThreshold: -1}, shouldErr: true},
+			}
+
+			for _, tt := range tests {
+				fn := func(t *testing.T) {
+					repo := &fakeRepo{
+						createFn: func(check health.Check) error { return nil },
+					}
+					svc := health.NewSVC(repo)
+
+					check := tt.check
+					check.Endpoint = "http://example.com"
+					c, err := svc.Create(check)
+					if tt.shouldErr {
+						mustError(t, err)
+						return
+					}
+					mustNoError(t, err)
+					equal(t, tt.check.Retries, c.Retries, "retries not kept")
+					equal(t, tt.check.RetryBackoff, c.RetryBackoff, "retry backoff not kept")
+					equal(t, tt.check.FailureThreshold, c.FailureThreshold, "failure threshold not kept")
+					equal(t, tt.check.SuccessThreshold, c.SuccessThreshold, "success threshold not kept")
+				}
+
+				t.Run(tt.name, fn)
+			}
+		})
+
+		t.Run("creation is recorded as an event", func(t *testing.T) {
+			repo := &fakeRepo{
+				createFn: func(check health.Check) error { return nil },
+			}
+			now := time.Unix(1500000000, 0)
+			history := health.NewMemoryHistoryRepository(10)
+			svc := health.NewSVC(repo, health.WithHistory(history), health.WithClock(newFakeClock(now)))
+
+			c, err := svc.Create(health.Check{Endpoint: "http://example.com"})
+			mustNoError(t, err)
+
+			events, err := history.Events(c.ID, now, now, 0)
+			mustNoError(t, err)
+			mustEqual(t, 1, len(events), "unexpected number of events")
+			equal(t, health.Event{CheckID: c.ID, Time: now, To: health.StatePending, Reason: "check created"}, events[0], "unexpected event")
+		})
+
+		t.Run("cert warning days", func(t *testing.T) {
+			repo := &fakeRepo{
+				createFn: func(check health.Check) error { return nil },
+			}
+			svc := health.NewSVC(repo)
+
+			c, err := svc.Create(health.Check{Endpoint: "https://example.com", CertWarningDays: 14})
+			mustNoError(t, err)
+			equal(t, 14, c.CertWarningDays, "cert warning days not kept")
+
+			_, err = svc.Create(health.Check{Endpoint: "https://example.com", CertWarningDays: -1})
+			mustError(t, err)
+
+			_, err = svc.Create(health.Check{Endpoint: "https://example.com", CertWarningDays: 366})
+			mustError(t, err)
+		})
+
 		t.Run("repo throws an error on creation", func(t *testing.T) {
 			expectedErr := errors.New("rando create error here")
 			repo := &fakeRepo{
@@ -79,7 +531,7 @@ func TestService(t *testing.T) {
 			}
 			svc := health.NewSVC(repo)
 
-			_, err := svc.Create("http://example.com")
+			_, err := svc.Create(health.Check{Endpoint: "http://example.com"})
 			equal(t, expectedErr, err, "did not receive expected repo error")
 		})
 	})
@@ -135,12 +587,796 @@ func TestService(t *testing.T) {
 			mustError(t, err)
 		})
 	})
+
+	t.Run("delete removes the check history", func(t *testing.T) {
+		id := strings.Repeat("a", 44)
+		now := time.Now()
+
+		history := health.NewMemoryHistoryRepository(10)
+		mustNoError(t, history.AppendResult(health.Result{CheckID: id, Checked: now}))
+
+		var deleted string
+		repo := &fakeRepo{
+			deleteFn: func(id string) error {
+				deleted = id
+				return nil
+			},
+		}
+		svc := health.NewSVC(repo, health.WithHistory(history))
+
+		mustNoError(t, svc.Delete(id))
+
+		equal(t, id, deleted, "check not deleted")
+		results, err := history.Results(id, now, now, 0)
+		mustNoError(t, err)
+		equal(t, 0, len(results), "history not deleted")
+	})
+
+	t.Run("delete waits for the probe being recorded", func(t *testing.T) {
+		id := strings.Repeat("a", 44)
+		now := time.Now()
+
+		updating, release := make(chan struct{}), make(chan struct{})
+		deleted := make(chan struct{})
+		repo := &fakeRepo{
+			readFn: func(id string) (health.Check, error) {
+				return health.Check{ID: id, Endpoint: "http://example.com"}, nil
+			},
+			updateFn: func(check health.Check) error {
+				close(updating)
+				<-release
+				return nil
+			},
+			deleteFn: func(id string) error {
+				close(deleted)
+				return nil
+			},
+		}
+		prober := &fakeProber{
+			probeFn: func(ctx context.Context, c health.Check) health.Result {
+				return health.Result{CheckID: c.ID, OK: true, Code: 200, Checked: now}
+			},
+		}
+		history := health.NewMemoryHistoryRepository(10)
+		svc := health.NewSVC(repo, health.WithProber(prober), health.WithHistory(history))
+
+		ran := make(chan error)
+		go func() {
+			_, err := svc.Run(context.Background(), id)
+			ran <- err
+		}()
+		<-updating
+
+		deleteErr := make(chan error)
+		go func() { deleteErr <- svc.Delete(id) }()
+
+		select {
+		case <-deleted:
+			t.Fatal("check deleted while its probe was being recorded")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(release)
+		mustNoError(t, <-ran)
+		mustNoError(t, <-deleteErr)
+
+		results, err := history.Results(id, now, now, 0)
+		mustNoError(t, err)
+		equal(t, 0, len(results), "history of the deleted check left behind")
+	})
+
+	t.Run("pause and resume", func(t *testing.T) {
+		id := strings.Repeat("a", 44)
+		now := time.Unix(1500000000, 0)
+
+		newSVC := func(check *health.Check, prober health.Prober) (health.SVC, health.HistoryRepository) {
+			repo := &fakeRepo{
+				readFn: func(id string) (health.Check, error) {
+					return *check, nil
+				},
+				updateFn: func(c health.Check) error {
+					*check = c
+					return nil
+				},
+			}
+			history := health.NewMemoryHistoryRepository(10)
+			return health.NewSVC(repo, health.WithProber(prober), health.WithHistory(history), health.WithClock(newFakeClock(now))), history
+		}
+
+		t.Run("paused check is not probed until resumed", func(t *testing.T) {
+			check := health.Check{ID: id, Endpoint: "http://example.com", State: health.StateUp, ConsecutiveSuccesses: 3}
+			var probes int
+			prober := &fakeProber{
+				probeFn: func(ctx context.Context, c health.Check) health.Result {
+					probes++
+					return health.Result{CheckID: c.ID, OK: true, Checked: now}
+				},
+			}
+			svc, history := newSVC(&check, prober)
+
+			paused, err := svc.Pause(id)
+			mustNoError(t, err)
+			equal(t, health.StatePaused, paused.State, "unexpected state")
+			equal(t, health.StatePaused, check.State, "paused state not persisted")
+
+			_, err = svc.Run(context.Background(), id)
+			mustError(t, err)
+			equal(t, 0, probes, "paused check was probed")
+
+			// pausing is idempotent
+			_, err = svc.Pause(id)
+			mustNoError(t, err)
+
+			resumed, err := svc.Resume(id)
+			mustNoError(t, err)
+			equal(t, health.StatePending, resumed.State, "unexpected state")
+			equal(t, 0, resumed.ConsecutiveSuccesses, "consecutive successes not reset")
+			equal(t, "http://example.com", resumed.Endpoint, "configuration not retained")
+
+			_, err = svc.Run(context.Background(), id)
+			mustNoError(t, err)
+			equal(t, 1, probes, "resumed check was not probed")
+
+			events, err := history.Events(id, now, now, 0)
+			mustNoError(t, err)
+			mustEqual(t, 3, len(events), "unexpected number of events")
+			equal(t, health.Event{CheckID: id, Time: now, From: health.StateUp, To: health.StatePaused, Reason: "check paused"}, events[0], "unexpected pause event")
+			equal(t, health.Event{CheckID: id, Time: now, From: health.StatePaused, To: health.StatePending, Reason: "check resumed"}, events[1], "unexpected resume event")
+			equal(t, health.StateUp, events[2].To, "unexpected probe event")
+		})
+
+		t.Run("probe in flight while paused is discarded", func(t *testing.T) {
+			check := health.Check{ID: id, Endpoint: "http://example.com", State: health.StateUp}
+			var svc health.SVC
+			prober := &fakeProber{
+				probeFn: func(ctx context.Context, c health.Check) health.Result {
+					_, err := svc.Pause(id)
+					mustNoError(t, err)
+					return health.Result{CheckID: c.ID, Checked: now}
+				},
+			}
+			svc, _ = newSVC(&check, prober)
+
+			_, err := svc.Run(context.Background(), id)
+			mustError(t, err)
+			equal(t, health.StatePaused, check.State, "paused check was updated by the probe")
+		})
+
+		t.Run("invalid id", func(t *testing.T) {
+			svc := health.NewSVC(&fakeRepo{})
+
+			_, err := svc.Pause("short")
+			mustError(t, err)
+			_, err = svc.Resume("short")
+			mustError(t, err)
+		})
+	})
+
+	t.Run("results", func(t *testing.T) {
+		id := strings.Repeat("a", 44)
+		now := time.Now()
+
+		history := health.NewMemoryHistoryRepository(100)
+		for i := 0; i < 5; i++ {
+			mustNoError(t, history.AppendResult(health.Result{
+				CheckID: id,
+				Code:    int32(200 + i),
+				Checked: now.Add(-time.Duration(i) * 20 * time.Minute),
+			}))
+		}
+
+		newSVC := func(readErr error) health.SVC {
+			repo := &fakeRepo{
+				readFn: func(id string) (health.Check, error) {
+					return health.Check{ID: id}, readErr
+				},
+			}
+			return health.NewSVC(repo, health.WithHistory(history))
+		}
+
+		t.Run("defaults to the last hour", func(t *testing.T) {
+			results, err := newSVC(nil).Results(id, time.Time{}, time.Time{}, 0)
+			mustNoError(t, err)
+
+			mustEqual(t, 3, len(results), "unexpected number of results")
+			equal(t, int32(202), results[0].Code, "unexpected first result")
+			equal(t, int32(200), results[2].Code, "unexpected last result")
+		})
+
+		t.Run("limits the results to the latest", func(t *testing.T) {
+			results, err := newSVC(nil).Results(id, now.Add(-2*time.Hour), now, 2)
+			mustNoError(t, err)
+
+			mustEqual(t, 2, len(results), "unexpected number of results")
+			equal(t, int32(201), results[0].Code, "unexpected first result")
+			equal(t, int32(200), results[1].Code, "unexpected last result")
+		})
+
+		t.Run("invalid params", func(t *testing.T) {
+			tests := []struct {
+				name     string
+				id       string
+				from, to time.Time
+				limit    int
+			}{
+				{name: "invalid id", id: "invalid"},
+				{name: "from after to", id: id, from: now, to: now.Add(-time.Minute)},
+				{name: "negative limit", id: id, limit: -1},
+				{name: "limit too large", id: id, limit: 100000},
+			}
+
+			for _, tt := range tests {
+				fn := func(t *testing.T) {
+					_, err := newSVC(nil).Results(tt.id, tt.from, tt.to, tt.limit)
+					mustError(t, err)
+				}
+
+				t.Run(tt.name, fn)
+			}
+		})
+
+		t.Run("check does not exist", func(t *testing.T) {
+			expectedErr := errors.New("not found")
+			_, err := newSVC(expectedErr).Results(id, time.Time{}, time.Time{}, 0)
+			equal(t, expectedErr, err, "unexpected error")
+		})
+	})
+
+	t.Run("uptime", func(t *testing.T) {
+		id := strings.Repeat("a", 44)
+
+		newSVC := func(results ...health.Result) health.SVC {
+			history := health.NewMemoryHistoryRepository(100)
+			for _, res := range results {
+				res.CheckID = id
+				mustNoError(t, history.AppendResult(res))
+			}
+			repo := &fakeRepo{
+				readFn: func(id string) (health.Check, error) {
+					return health.Check{ID: id}, nil
+				},
+			}
+			return health.NewSVC(repo, health.WithHistory(history))
+		}
+
+		t.Run("computes availability, downtime, incidents and mttr", func(t *testing.T) {
+			now := time.Now()
+			svc := newSVC(
+				health.Result{OK: false, Checked: now.Add(-48 * time.Hour)},
+				health.Result{OK: true, Checked: now.Add(-4 * time.Hour)},
+				health.Result{OK: false, Checked: now.Add(-3 * time.Hour)},
+				health.Result{OK: false, Checked: now.Add(-150 * time.Minute)},
+				health.Result{OK: true, Checked: now.Add(-2 * time.Hour)},
+				health.Result{OK: false, Checked: now.Add(-time.Hour)},
+				health.Result{OK: true, Checked: now.Add(-30 * time.Minute)},
+			)
+
+			uptime, err := svc.Uptime(id, 0)
+			mustNoError(t, err)
+
+			equal(t, id, uptime.CheckID, "unexpected check id")
+			equal(t, health.Duration(24*time.Hour), uptime.Window, "unexpected default window")
+			if d := time.Duration(uptime.Observed) - 4*time.Hour; d < 0 || d > time.Minute {
+				t.Errorf("unexpected observed span: got=%s", time.Duration(uptime.Observed))
+			}
+			equal(t, 6, uptime.Probes, "unexpected probes")
+			equal(t, 2, uptime.Incidents, "unexpected incidents")
+			equal(t, health.Duration(90*time.Minute), uptime.Downtime, "unexpected downtime")
+			equal(t, health.Duration(45*time.Minute), uptime.MTTR, "unexpected mttr")
+			if uptime.Availability == nil {
+				t.Fatal("availability not computed")
+			}
+			if a := *uptime.Availability; a < 62.49 || a > 62.51 {
+				t.Errorf("unexpected availability: expected=62.5 got=%f", a)
+			}
+		})
+
+		t.Run("ongoing incident counts towards downtime but not mttr", func(t *testing.T) {
+			now := time.Now()
+			svc := newSVC(
+				health.Result{OK: true, Checked: now.Add(-2 * time.Hour)},
+				health.Result{OK: false, Checked: now.Add(-time.Hour)},
+			)
+
+			uptime, err := svc.Uptime(id, 7*24*time.Hour)
+			mustNoError(t, err)
+
+			equal(t, 1, uptime.Incidents, "unexpected incidents")
+			equal(t, health.Duration(0), uptime.MTTR, "unexpected mttr")
+			if uptime.Downtime < health.Duration(time.Hour) {
+				t.Errorf("unexpected downtime: got=%s", time.Duration(uptime.Downtime))
+			}
+		})
+
+		t.Run("observed span is limited to the results retained", func(t *testing.T) {
+			now := time.Now()
+			history := health.NewMemoryHistoryRepository(2)
+			for _, ago := range []time.Duration{20 * time.Hour, 10 * time.Hour, 5 * time.Hour} {
+				mustNoError(t, history.AppendResult(health.Result{CheckID: id, OK: true, Checked: now.Add(-ago)}))
+			}
+			repo := &fakeRepo{
+				readFn: func(id string) (health.Check, error) {
+					return health.Check{ID: id}, nil
+				},
+			}
+			svc := health.NewSVC(repo, health.WithHistory(history), health.WithClock(newFakeClock(now)))
+
+			uptime, err := svc.Uptime(id, 0)
+			mustNoError(t, err)
+
+			equal(t, health.Duration(24*time.Hour), uptime.Window, "unexpected window")
+			equal(t, health.Duration(10*time.Hour), uptime.Observed, "unexpected observed span")
+			equal(t, 2, uptime.Probes, "unexpected probes")
+		})
+
+		t.Run("no probes within the window", func(t *testing.T) {
+			uptime, err := newSVC().Uptime(id, 0)
+			mustNoError(t, err)
+
+			equal(t, 0, uptime.Probes, "unexpected probes")
+			if uptime.Availability != nil {
+				t.Errorf("expected no availability: got=%f", *uptime.Availability)
+			}
+		})
+
+		t.Run("invalid window", func(t *testing.T) {
+			for _, window := range []time.Duration{-time.Hour, 31 * 24 * time.Hour} {
+				_, err := newSVC().Uptime(id, window)
+				mustError(t, err)
+			}
+		})
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		id := strings.Repeat("a", 44)
+
+		newSVC := func(history health.HistoryRepository, opts ...health.SVCOption) health.SVC {
+			repo := &fakeRepo{
+				readFn: func(id string) (health.Check, error) {
+					return health.Check{ID: id}, nil
+				},
+				updateFn: func(check health.Check) error { return nil },
+			}
+			return health.NewSVC(repo, append(opts, health.WithHistory(history))...)
+		}
+
+		within := func(t *testing.T, expected time.Duration, got health.Duration, msg string) {
+			t.Helper()
+
+			diff := float64(time.Duration(got)-expected) / float64(expected)
+			if diff < -0.02 || diff > 0.02 {
+				t.Errorf("%s: expected=%s got=%s", msg, expected, time.Duration(got))
+			}
+		}
+
+		t.Run("percentiles are computed from the recorded latencies", func(t *testing.T) {
+			now := time.Now()
+			history := health.NewMemoryHistoryRepository(20000)
+			for i := 10000; i > 0; i-- {
+				mustNoError(t, history.AppendResult(health.Result{
+					CheckID:  id,
+					OK:       true,
+					Checked:  now.Add(-time.Duration(i) * 300 * time.Millisecond),
+					Duration: health.Duration(time.Duration(i) * time.Millisecond),
+				}))
+			}
+			// failed probes without a response are not considered
+			mustNoError(t, history.AppendResult(health.Result{
+				CheckID:  id,
+				Checked:  now,
+				Duration: health.Duration(time.Hour),
+				Error:    "connection refused",
+			}))
+
+			stats, err := newSVC(history).Stats(id, 2*time.Hour)
+			mustNoError(t, err)
+
+			equal(t, id, stats.CheckID, "unexpected check id")
+			equal(t, health.Duration(2*time.Hour), stats.Window, "unexpected window")
+			equal(t, uint64(10000), stats.Count, "unexpected count")
+			equal(t, health.Duration(time.Millisecond), stats.Min, "unexpected min")
+			equal(t, health.Duration(10*time.Second), stats.Max, "unexpected max")
+			within(t, 5000*time.Millisecond, stats.Mean, "unexpected mean")
+			within(t, 5000*time.Millisecond, stats.P50, "unexpected p50")
+			within(t, 9000*time.Millisecond, stats.P90, "unexpected p90")
+			within(t, 9500*time.Millisecond, stats.P95, "unexpected p95")
+			within(t, 9900*time.Millisecond, stats.P99, "unexpected p99")
+		})
+
+		t.Run("window only includes recent latencies", func(t *testing.T) {
+			now := time.Now()
+			history := health.NewMemoryHistoryRepository(100)
+			mustNoError(t, history.AppendResult(health.Result{
+				CheckID:  id,
+				Checked:  now.Add(-72 * time.Hour),
+				Duration: health.Duration(time.Second),
+			}))
+
+			svc := newSVC(history, health.WithProber(&fakeProber{
+				probeFn: func(ctx context.Context, c health.Check) health.Result {
+					return health.Result{CheckID: c.ID, OK: true, Checked: time.Now(), Duration: health.Duration(20 * time.Millisecond)}
+				},
+			}))
+			for i := 0; i < 3; i++ {
+				_, err := svc.Run(context.Background(), id)
+				mustNoError(t, err)
+			}
+
+			stats, err := svc.Stats(id, 0)
+			mustNoError(t, err)
+			equal(t, health.Duration(time.Hour), stats.Window, "unexpected default window")
+			equal(t, uint64(3), stats.Count, "unexpected count")
+			within(t, 20*time.Millisecond, stats.P99, "unexpected p99")
+
+			stats, err = svc.Stats(id, 7*24*time.Hour)
+			mustNoError(t, err)
+			equal(t, uint64(4), stats.Count, "unexpected count")
+			equal(t, health.Duration(time.Second), stats.Max, "unexpected max")
+		})
+
+		t.Run("history is seeded once when probed and read concurrently", func(t *testing.T) {
+			now := time.Now()
+			history := health.NewMemoryHistoryRepository(100)
+			for i := 0; i < 5; i++ {
+				mustNoError(t, history.AppendResult(health.Result{
+					CheckID:  id,
+					OK:       true,
+					Checked:  now.Add(-time.Duration(i+1) * time.Minute),
+					Duration: health.Duration(10 * time.Millisecond),
+				}))
+			}
+
+			svc := newSVC(history, health.WithProber(&fakeProber{
+				probeFn: func(ctx context.Context, c health.Check) health.Result {
+					return health.Result{CheckID: c.ID, OK: true, Checked: time.Now(), Duration: health.Duration(10 * time.Millisecond)}
+				},
+			}))
+
+			const runs = 10
+			var wg sync.WaitGroup
+			for i := 0; i < runs; i++ {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					if _, err := svc.Run(context.Background(), id); err != nil {
+						t.Error(err)
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					if _, err := svc.Stats(id, 0); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			stats, err := svc.Stats(id, 0)
+			mustNoError(t, err)
+			equal(t, uint64(5+runs), stats.Count, "unexpected count")
+		})
+
+		t.Run("seeding does not hold up the probes of other checks", func(t *testing.T) {
+			otherID := strings.Repeat("b", 44)
+			history := &blockingHistory{
+				HistoryRepository: health.NewMemoryHistoryRepository(10),
+				id:                id,
+				release:           make(chan struct{}),
+			}
+			defer close(history.release)
+
+			svc := newSVC(history, health.WithProber(&fakeProber{
+				probeFn: func(ctx context.Context, c health.Check) health.Result {
+					return health.Result{CheckID: c.ID, OK: true, Checked: time.Now()}
+				},
+			}))
+			go svc.Run(context.Background(), id)
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := svc.Run(context.Background(), otherID)
+				done <- err
+			}()
+
+			select {
+			case err := <-done:
+				mustNoError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("probe of another check blocked by seeding")
+			}
+		})
+
+		t.Run("no latencies within the window", func(t *testing.T) {
+			stats, err := newSVC(health.NewMemoryHistoryRepository(10)).Stats(id, 0)
+			mustNoError(t, err)
+
+			equal(t, uint64(0), stats.Count, "unexpected count")
+			equal(t, health.Duration(0), stats.P50, "unexpected p50")
+		})
+
+		t.Run("invalid window", func(t *testing.T) {
+			_, err := newSVC(health.NewMemoryHistoryRepository(10)).Stats(id, 31*24*time.Hour)
+			mustError(t, err)
+		})
+	})
+
+	t.Run("run", func(t *testing.T) {
+		id := strings.Repeat("a", 44)
+		checked := time.Unix(1500000000, 0)
+
+		newRepo := func(updated *health.Check) *fakeRepo {
+			return &fakeRepo{
+				readFn: func(id string) (health.Check, error) {
+					return health.Check{ID: id, Endpoint: "http://example.com", State: health.StatePending}, nil
+				},
+				updateFn: func(check health.Check) error {
+					*updated = check
+					return nil
+				},
+			}
+		}
+
+		tests := []struct {
+			name          string
+			result        health.Result
+			expectedState health.State
+		}{
+			{
+				name:          "successful probe marks check up",
+				result:        health.Result{OK: true, Code: 200, Checked: checked, Duration: health.Duration(15 * time.Millisecond)},
+				expectedState: health.StateUp,
+			},
+			{
+				name:          "failed probe marks check down",
+				result:        health.Result{Code: 503, Checked: checked, Duration: health.Duration(15 * time.Millisecond)},
+				expectedState: health.StateDown,
+			},
+			{
+				name:          "unknown probe marks check unknown",
+				result:        health.Result{Unknown: true, Checked: checked, Duration: health.Duration(15 * time.Millisecond)},
+				expectedState: health.StateUnknown,
+			},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				var updated health.Check
+				prober := &fakeProber{
+					probeFn: func(ctx context.Context, c health.Check) health.Result {
+						res := tt.result
+						res.CheckID = c.ID
+						return res
+					},
+				}
+				svc := health.NewSVC(newRepo(&updated), health.WithProber(prober))
+
+				check, err := svc.Run(context.Background(), id)
+				mustNoError(t, err)
+
+				equal(t, updated, check, "returned check does not match persisted check")
+				equal(t, tt.expectedState, check.State, "unexpected status")
+				equal(t, tt.result.Code, check.Code, "unexpected code")
+				equal(t, checked.Unix(), check.Checked, "unexpected checked")
+				equal(t, "15ms", check.Duration, "unexpected duration")
+			}
+
+			t.Run(tt.name, fn)
+		}
+
+		t.Run("failed assertion is recorded on the check", func(t *testing.T) {
+			var updated health.Check
+			prober := &fakeProber{
+				probeFn: func(ctx context.Context, c health.Check) health.Result {
+					return health.Result{
+						CheckID:   c.ID,
+						Code:      200,
+						Checked:   checked,
+						Assertion: health.AssertionJSONPath,
+						Failure:   `$.status is "degraded", expected "ok"`,
+					}
+				},
+			}
+			svc := health.NewSVC(newRepo(&updated), health.WithProber(prober))
+
+			check, err := svc.Run(context.Background(), id)
+			mustNoError(t, err)
+
+			equal(t, health.StateDown, check.State, "unexpected status")
+			equal(t, `json_path: $.status is "degraded", expected "ok"`, check.Failure, "unexpected failure")
+		})
+
+		t.Run("certificate nearing expiry marks check warning", func(t *testing.T) {
+			tls := &health.TLSInfo{
+				Expiry:        checked.Add(5 * 24 * time.Hour),
+				Issuer:        "CN=Test CA",
+				DaysRemaining: 5,
+			}
+
+			tests := []struct {
+				name            string
+				certWarningDays int
+				expectedState   health.State
+			}{
+				{name: "within threshold", certWarningDays: 14, expectedState: health.StateDegraded},
+				{name: "outside threshold", certWarningDays: 3, expectedState: health.StateUp},
+				{name: "no threshold", expectedState: health.StateUp},
+			}
+
+			for _, tt := range tests {
+				fn := func(t *testing.T) {
+					var updated health.Check
+					repo := newRepo(&updated)
+					repo.readFn = func(id string) (health.Check, error) {
+						return health.Check{ID: id, Endpoint: "https://example.com", CertWarningDays: tt.certWarningDays}, nil
+					}
+					prober := &fakeProber{
+						probeFn: func(ctx context.Context, c health.Check) health.Result {
+							return health.Result{CheckID: c.ID, OK: true, Code: 200, Checked: checked, TLS: tls}
+						},
+					}
+					svc := health.NewSVC(repo, health.WithProber(prober))
+
+					check, err := svc.Run(context.Background(), id)
+					mustNoError(t, err)
+
+					equal(t, tt.expectedState, check.State, "unexpected status")
+					equal(t, tls, check.TLS, "tls info not recorded")
+				}
+
+				t.Run(tt.name, fn)
+			}
+		})
+
+		t.Run("transitions are recorded as events", func(t *testing.T) {
+			check := health.Check{ID: id, Endpoint: "http://example.com", State: health.StatePending, FailureThreshold: 2}
+			repo := &fakeRepo{
+				readFn: func(id string) (health.Check, error) {
+					return check, nil
+				},
+				updateFn: func(c health.Check) error {
+					check = c
+					return nil
+				},
+			}
+
+			var probes int
+			prober := &fakeProber{
+				probeFn: func(ctx context.Context, c health.Check) health.Result {
+					probes++
+					res := health.Result{CheckID: c.ID, Code: 200, OK: true, Checked: checked.Add(time.Duration(probes) * time.Minute)}
+					if probes > 1 {
+						res.Code, res.OK, res.Assertion, res.Failure = 503, false, health.AssertionCodes, "status code 503 is not one of the expected codes"
+					}
+					return res
+				},
+			}
+			svc := health.NewSVC(repo, health.WithProber(prober), health.WithClock(newFakeClock(checked.Add(time.Hour))))
+
+			for i := 0; i < 4; i++ {
+				_, err := svc.Run(context.Background(), id)
+				mustNoError(t, err)
+			}
+
+			events, err := svc.Events(id, time.Time{}, time.Time{}, 0)
+			mustNoError(t, err)
+
+			mustEqual(t, 2, len(events), "unexpected number of events")
+			equal(t, health.Event{
+				CheckID: id,
+				Time:    checked.Add(time.Minute),
+				From:    health.StatePending,
+				To:      health.StateUp,
+				Reason:  "probe succeeded",
+			}, events[0], "unexpected up event")
+			equal(t, health.Event{
+				CheckID: id,
+				Time:    checked.Add(3 * time.Minute),
+				From:    health.StateUp,
+				To:      health.StateDown,
+				Reason:  "2 consecutive probes failed: codes: status code 503 is not one of the expected codes",
+			}, events[1], "unexpected down event")
+		})
+
+		t.Run("result is appended to the history", func(t *testing.T) {
+			var updated health.Check
+			prober := &fakeProber{
+				probeFn: func(ctx context.Context, c health.Check) health.Result {
+					return health.Result{CheckID: c.ID, OK: true, Code: 200, Checked: checked}
+				},
+			}
+			history := health.NewMemoryHistoryRepository(10)
+			svc := health.NewSVC(newRepo(&updated), health.WithProber(prober), health.WithHistory(history))
+
+			_, err := svc.Run(context.Background(), id)
+			mustNoError(t, err)
+
+			results, err := history.Results(id, checked, checked, 0)
+			mustNoError(t, err)
+			mustEqual(t, 1, len(results), "unexpected number of results")
+			equal(t, int32(200), results[0].Code, "unexpected result")
+		})
+
+		t.Run("probe is exposed as metrics", func(t *testing.T) {
+			var updated health.Check
+			repo := newRepo(&updated)
+			repo.listFn = func(page, size int) (int, []health.Check) {
+				return 1, []health.Check{updated}
+			}
+			prober := &fakeProber{
+				probeFn: func(ctx context.Context, c health.Check) health.Result {
+					return health.Result{CheckID: c.ID, OK: true, Code: 200, Checked: checked, Duration: health.Duration(20 * time.Millisecond)}
+				},
+			}
+			reg := prometheus.NewRegistry()
+			svc := health.NewSVC(repo, health.WithProber(prober), health.WithMetrics(reg))
+
+			_, err := svc.Run(context.Background(), id)
+			mustNoError(t, err)
+
+			rec := httptest.NewRecorder()
+			promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			body := rec.Body.String()
+
+			labels := `{endpoint="http://example.com",id="` + id + `"`
+			for _, expected := range []string{
+				"health_probes_total" + labels + `,result="success"} 1`,
+				"health_probe_duration_seconds_bucket" + labels + `,le="0.025"} 1`,
+				"health_probe_duration_seconds_count" + labels + "} 1",
+				"health_check_up" + labels + "} 1",
+				"health_check_status_code" + labels + "} 200",
+				"health_check_latency_seconds" + labels + "} 0.02",
+				"health_check_last_probe_timestamp_seconds" + labels + "} 1.5e+09",
+			} {
+				if !strings.Contains(body, expected+"\n") {
+					t.Errorf("missing metric %q in:\n%s", expected, body)
+				}
+			}
+		})
+
+		t.Run("probe is bounded by the check timeout", func(t *testing.T) {
+			repo := &fakeRepo{
+				readFn: func(id string) (health.Check, error) {
+					return health.Check{ID: id, Timeout: health.Duration(time.Millisecond)}, nil
+				},
+				updateFn: func(check health.Check) error { return nil },
+			}
+			prober := &fakeProber{
+				probeFn: func(ctx context.Context, c health.Check) health.Result {
+					<-ctx.Done()
+					return health.Result{CheckID: c.ID, Checked: time.Now(), Error: ctx.Err().Error()}
+				},
+			}
+			svc := health.NewSVC(repo, health.WithProber(prober))
+
+			check, err := svc.Run(context.Background(), id)
+			mustNoError(t, err)
+			equal(t, health.StateDown, check.State, "timed out probe should mark check down")
+		})
+
+		t.Run("canceled probe is not recorded", func(t *testing.T) {
+			var updated health.Check
+			ctx, cancel := context.WithCancel(context.Background())
+			prober := &fakeProber{
+				probeFn: func(_ context.Context, c health.Check) health.Result {
+					cancel()
+					return health.Result{CheckID: c.ID, Error: "context canceled"}
+				},
+			}
+			svc := health.NewSVC(newRepo(&updated), health.WithProber(prober))
+
+			_, err := svc.Run(ctx, id)
+			mustError(t, err)
+			equal(t, "", updated.ID, "canceled probe should not be persisted")
+		})
+	})
 }
 
 type fakeRepo struct {
 	createFn func(check health.Check) error
 	listFn   func(page, size int) (int, []health.Check)
 	readFn   func(id string) (health.Check, error)
+	updateFn func(check health.Check) error
+	deleteFn func(id string) error
 }
 
 func (f *fakeRepo) Create(check health.Check) error {
@@ -163,3 +1399,42 @@ func (f *fakeRepo) Read(id string) (health.Check, error) {
 	}
 	return f.readFn(id)
 }
+
+func (f *fakeRepo) Update(check health.Check) error {
+	if f.updateFn == nil {
+		panic("update not implemented")
+	}
+	return f.updateFn(check)
+}
+
+func (f *fakeRepo) Delete(id string) error {
+	if f.deleteFn == nil {
+		panic("delete not implemented")
+	}
+	return f.deleteFn(id)
+}
+
+type fakeProber struct {
+	probeFn func(ctx context.Context, c health.Check) health.Result
+}
+
+func (f *fakeProber) Probe(ctx context.Context, c health.Check) health.Result {
+	if f.probeFn == nil {
+		panic("probe not implemented")
+	}
+	return f.probeFn(ctx, c)
+}
+
+// blockingHistory blocks reading the results of the check until released.
+type blockingHistory struct {
+	health.HistoryRepository
+	id      string
+	release chan struct{}
+}
+
+func (b *blockingHistory) Results(id string, from, to time.Time, limit int) ([]health.Result, error) {
+	if id == b.id {
+		<-b.release
+	}
+	return b.HistoryRepository.Results(id, from, to, limit)
+}
@@ -0,0 +1,99 @@
+package health
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// sketchAccuracy is the relative error of the quantiles provided by a sketch.
+const sketchAccuracy = 0.01
+
+var (
+	sketchGamma    = (1 + sketchAccuracy) / (1 - sketchAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// sketch is a mergeable quantile sketch of durations with logarithmically
+// sized bins, in the vein of DDSketch. Its size is bound by the range of
+// durations added rather than the number of durations added.
+type sketch struct {
+	bins     map[int]uint64
+	count    uint64
+	sum      time.Duration
+	min, max time.Duration
+}
+
+func newSketch() *sketch {
+	return &sketch{bins: make(map[int]uint64)}
+}
+
+func (s *sketch) add(d time.Duration) {
+	if d < 1 {
+		d = 1
+	}
+
+	s.bins[int(math.Ceil(math.Log(float64(d))/sketchLogGamma))]++
+	if s.count == 0 || d < s.min {
+		s.min = d
+	}
+	if d > s.max {
+		s.max = d
+	}
+	s.count++
+	s.sum += d
+}
+
+func (s *sketch) merge(o *sketch) {
+	if o.count == 0 {
+		return
+	}
+
+	for k, n := range o.bins {
+		s.bins[k] += n
+	}
+	if s.count == 0 || o.min < s.min {
+		s.min = o.min
+	}
+	if o.max > s.max {
+		s.max = o.max
+	}
+	s.count += o.count
+	s.sum += o.sum
+}
+
+func (s *sketch) mean() time.Duration {
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / time.Duration(s.count)
+}
+
+func (s *sketch) quantile(q float64) time.Duration {
+	if s.count == 0 {
+		return 0
+	}
+
+	keys := make([]int, 0, len(s.bins))
+	for k := range s.bins {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	rank := uint64(q * float64(s.count-1))
+	var seen uint64
+	for _, k := range keys {
+		seen += s.bins[k]
+		if seen > rank {
+			v := time.Duration(2 * math.Pow(sketchGamma, float64(k)) / (sketchGamma + 1))
+			switch {
+			case v < s.min:
+				return s.min
+			case v > s.max:
+				return s.max
+			}
+			return v
+		}
+	}
+	return s.max
+}
@@ -0,0 +1,120 @@
+package health
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// writeFileAtomic replaces the file at path with b. Should the process, or
+// the machine, die while it is written the file holds either its previous
+// contents or all of b, never a part of b.
+func writeFileAtomic(path string, b []byte, perm os.FileMode) error {
+	tmp, err := writeTempFile(path, b, perm)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	return replaceFile(tmp, path)
+}
+
+// writeTempFile writes b to a temporary file alongside path, synced to disk,
+// providing the name of the temporary file.
+func writeTempFile(path string, b []byte, perm os.FileMode) (string, error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return "", err
+	}
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// replaceFile renames the synced file tmp over the file at path.
+func replaceFile(tmp, path string) error {
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// the rename is only durable once the directory is synced
+	return syncDir(filepath.Dir(path))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+func backupPath(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// rotateBackups keeps the file at path as the latest of n backups, shifting
+// the older backups along and dropping the oldest. The backups are hard links
+// so the file at path remains in place until it is replaced.
+//
+// The file is only kept when it was written at least every later than the
+// latest backup, so the backups span a useful period of time however often the file
+// is replaced, and replacing the file again after a failure does not cycle
+// the latest backup through the older ones.
+func rotateBackups(path string, n int, every time.Duration) error {
+	if n <= 0 {
+		return nil
+	}
+
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && fi.Size() == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	latest, err := os.Stat(backupPath(path, 1))
+	switch {
+	case err == nil:
+		if os.SameFile(fi, latest) || fi.ModTime().Sub(latest.ModTime()) < every {
+			return nil
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	for i := n - 1; i >= 1; i-- {
+		err := os.Rename(backupPath(path, i), backupPath(path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Remove(backupPath(path, 1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Link(path, backupPath(path, 1))
+}
@@ -0,0 +1,434 @@
+package health
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order, each exactly once, when the database
+// is opened. The migrations are forward only, a migration that has been
+// released must never change, the schema is changed by appending another.
+var sqliteMigrations = []string{
+	// 1: checks and their probe results and events. The columns commonly
+	// queried are split out of the check, which is stored whole as JSON.
+	// Times are stored as unix nanoseconds.
+	`
+	CREATE TABLE checks (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		id         TEXT NOT NULL UNIQUE,
+		type       TEXT NOT NULL,
+		endpoint   TEXT NOT NULL,
+		state      TEXT NOT NULL,
+		code       INTEGER NOT NULL,
+		checked    INTEGER NOT NULL,
+		labels     TEXT,
+		check_json TEXT NOT NULL
+	);
+	CREATE INDEX checks_state ON checks (state);
+	CREATE INDEX checks_type ON checks (type);
+	CREATE INDEX checks_endpoint ON checks (endpoint);
+
+	CREATE TABLE probe_results (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		check_id    TEXT NOT NULL,
+		ok          INTEGER NOT NULL,
+		code        INTEGER NOT NULL,
+		checked     INTEGER NOT NULL,
+		duration    INTEGER NOT NULL,
+		error       TEXT NOT NULL,
+		assertion   TEXT NOT NULL,
+		failure     TEXT NOT NULL,
+		unknown     INTEGER NOT NULL,
+		maintenance TEXT NOT NULL,
+		tls         TEXT
+	);
+	CREATE INDEX probe_results_check_checked ON probe_results (check_id, checked);
+	CREATE INDEX probe_results_checked ON probe_results (checked);
+
+	CREATE TABLE check_events (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		check_id   TEXT NOT NULL,
+		time       INTEGER NOT NULL,
+		from_state TEXT NOT NULL,
+		to_state   TEXT NOT NULL,
+		reason     TEXT NOT NULL
+	);
+	CREATE INDEX check_events_check_time ON check_events (check_id, time);
+	`,
+}
+
+// SQLiteRepository persists the checks, and their history, to a SQLite
+// database. Results and events older than the retention are dropped. Checks
+// holding secrets inline are refused, see errSQLiteInlineSecret.
+type SQLiteRepository struct {
+	db        *sql.DB
+	retention time.Duration
+
+	mu sync.Mutex
+	// pruned is the time of the latest result the expired history was
+	// pruned at, the history is pruned once every tenth of the retention,
+	// and at least every maxPruneEvery.
+	pruned time.Time
+}
+
+var (
+	_ Repository        = (*SQLiteRepository)(nil)
+	_ HistoryRepository = (*SQLiteRepository)(nil)
+)
+
+// errSQLiteInlineSecret is returned for checks holding secrets inline, as the
+// database is there to be queried by anyone analysing the checks, the secrets
+// of a check must be referenced with a secret_ref instead.
+var errSQLiteInlineSecret = errors.New("the sqlite repo does not persist secrets, auth must use a secret_ref and headers other than Accept, Content-Type, User-Agent and the like must be secret_headers")
+
+const maxPruneEvery = time.Hour
+
+// NewSQLiteRepository opens the SQLite database at path, creating it when it
+// does not exist, and migrates it to the latest schema.
+func NewSQLiteRepository(path string, retention time.Duration) (*SQLiteRepository, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// writes are serialized by SQLite regardless, a single connection
+	// saves them from waiting on one another.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db, sqliteMigrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteRepository{db: db, retention: retention}, nil
+}
+
+func (r *SQLiteRepository) Close() error {
+	return r.db.Close()
+}
+
+func migrate(db *sql.DB, migrations []string) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than the latest known version %d", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %s", i+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, i+1, time.Now().UnixNano()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) Create(check Check) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM checks WHERE id = ?`, check.ID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		return errEndpointExists
+	}
+
+	args, err := checkColumns(check)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO checks (id, type, endpoint, state, code, checked, labels, check_json)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, args...)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteRepository) List(page, size int) (int, []Check) {
+	out := []Check{}
+
+	// errors are not surfaced by List, no checks are provided
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM checks`).Scan(&total); err != nil {
+		return 0, out
+	}
+
+	limit, offset := -1, 0
+	if size != -1 {
+		limit, offset = size, size*(page-1)
+		if offset < 0 {
+			offset = 0
+		}
+	}
+
+	rows, err := r.db.Query(`SELECT check_json FROM checks ORDER BY seq LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return total, out
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		check, err := scanCheck(rows)
+		if err != nil {
+			return total, out
+		}
+		out = append(out, check)
+	}
+	return total, out
+}
+
+func (r *SQLiteRepository) Read(id string) (Check, error) {
+	check, err := scanCheck(r.db.QueryRow(`SELECT check_json FROM checks WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return Check{}, errCheckNotFound
+	}
+	return check, err
+}
+
+func (r *SQLiteRepository) Update(check Check) error {
+	args, err := checkColumns(check)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.db.Exec(`UPDATE checks
+		SET type = ?2, endpoint = ?3, state = ?4, code = ?5, checked = ?6, labels = ?7, check_json = ?8
+		WHERE id = ?1`, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errCheckNotFound
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM checks WHERE id = ?`, id)
+	return err
+}
+
+func checkColumns(c Check) ([]interface{}, error) {
+	if c.Request.hasSecrets() {
+		return nil, errSQLiteInlineSecret
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var labels interface{}
+	if len(c.Labels) > 0 {
+		l, err := json.Marshal(c.Labels)
+		if err != nil {
+			return nil, err
+		}
+		labels = string(l)
+	}
+	return []interface{}{c.ID, c.Type, c.Endpoint, string(c.State), c.Code, c.Checked, labels, string(b)}, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCheck(s scanner) (Check, error) {
+	var b string
+	if err := s.Scan(&b); err != nil {
+		return Check{}, err
+	}
+
+	var c Check
+	if err := json.Unmarshal([]byte(b), &c); err != nil {
+		return Check{}, err
+	}
+	return c, nil
+}
+
+func (r *SQLiteRepository) AppendResult(res Result) error {
+	var tls interface{}
+	if res.TLS != nil {
+		b, err := json.Marshal(res.TLS)
+		if err != nil {
+			return err
+		}
+		tls = string(b)
+	}
+
+	_, err := r.db.Exec(`INSERT INTO probe_results
+		(check_id, ok, code, checked, duration, error, assertion, failure, unknown, maintenance, tls)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		res.CheckID, res.OK, res.Code, res.Checked.UnixNano(), int64(res.Duration),
+		res.Error, res.Assertion, res.Failure, res.Unknown, res.Maintenance, tls,
+	)
+	if err != nil {
+		return err
+	}
+	return r.prune(res.Checked)
+}
+
+// prune drops the results and events older than the retention.
+func (r *SQLiteRepository) prune(now time.Time) error {
+	if r.retention <= 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	every := r.retention / 10
+	if every > maxPruneEvery {
+		every = maxPruneEvery
+	}
+	if now.Sub(r.pruned) < every {
+		return nil
+	}
+
+	cutoff := now.Add(-r.retention).UnixNano()
+	if _, err := r.db.Exec(`DELETE FROM probe_results WHERE checked < ?`, cutoff); err != nil {
+		return err
+	}
+	if _, err := r.db.Exec(`DELETE FROM check_events WHERE time < ?`, cutoff); err != nil {
+		return err
+	}
+	r.pruned = now
+	return nil
+}
+
+func (r *SQLiteRepository) Results(id string, from, to time.Time, limit int) ([]Result, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+
+	// the latest results are limited to, and then put back in ascending order
+	rows, err := r.db.Query(`SELECT check_id, ok, code, checked, duration, error, assertion, failure, unknown, maintenance, tls
+		FROM (
+			SELECT * FROM probe_results
+			WHERE check_id = ? AND checked BETWEEN ? AND ?
+			ORDER BY checked DESC, id DESC
+			LIMIT ?
+		)
+		ORDER BY checked, id`, id, from.UnixNano(), to.UnixNano(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Result{}
+	for rows.Next() {
+		var (
+			res      Result
+			checked  int64
+			duration int64
+			tls      sql.NullString
+		)
+		err := rows.Scan(&res.CheckID, &res.OK, &res.Code, &checked, &duration,
+			&res.Error, &res.Assertion, &res.Failure, &res.Unknown, &res.Maintenance, &tls)
+		if err != nil {
+			return nil, err
+		}
+		res.Checked = time.Unix(0, checked)
+		res.Duration = Duration(duration)
+		if tls.Valid {
+			res.TLS = new(TLSInfo)
+			if err := json.Unmarshal([]byte(tls.String), res.TLS); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, res)
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteRepository) AppendEvent(e Event) error {
+	_, err := r.db.Exec(`INSERT INTO check_events (check_id, time, from_state, to_state, reason) VALUES (?, ?, ?, ?, ?)`,
+		e.CheckID, e.Time.UnixNano(), string(e.From), string(e.To), e.Reason)
+	return err
+}
+
+func (r *SQLiteRepository) Events(id string, from, to time.Time, limit int) ([]Event, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := r.db.Query(`SELECT check_id, time, from_state, to_state, reason
+		FROM (
+			SELECT * FROM check_events
+			WHERE check_id = ? AND time BETWEEN ? AND ?
+			ORDER BY time DESC, id DESC
+			LIMIT ?
+		)
+		ORDER BY time, id`, id, from.UnixNano(), to.UnixNano(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Event{}
+	for rows.Next() {
+		var (
+			e        Event
+			t        int64
+			from, to string
+		)
+		if err := rows.Scan(&e.CheckID, &t, &from, &to, &e.Reason); err != nil {
+			return nil, err
+		}
+		e.Time, e.From, e.To = time.Unix(0, t), State(from), State(to)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteRepository) DeleteHistory(id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM probe_results WHERE check_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM check_events WHERE check_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,298 @@
+package health_test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestSQLiteRepository(t *testing.T) {
+	newRepo := func(t *testing.T, retention time.Duration) (*health.SQLiteRepository, string, func()) {
+		t.Helper()
+
+		tmpDir, err := ioutil.TempDir("", "")
+		mustNoError(t, err)
+
+		path := filepath.Join(tmpDir, "endpoints.sqlite")
+		repo, err := health.NewSQLiteRepository(path, retention)
+		mustNoError(t, err)
+
+		return repo, path, func() {
+			repo.Close()
+			os.RemoveAll(tmpDir)
+		}
+	}
+
+	newChecks := func(n int) []health.Check {
+		checks := make([]health.Check, 0, n)
+		for i := 0; i < n; i++ {
+			checks = append(checks, health.Check{ID: "id-" + strconv.Itoa(i), Type: health.TypeHTTP, Endpoint: "http://example.com/" + strconv.Itoa(i)})
+		}
+		return checks
+	}
+
+	t.Run("checks", func(t *testing.T) {
+		repo, _, done := newRepo(t, 0)
+		defer done()
+
+		check := health.Check{
+			ID:       "id",
+			Type:     health.TypeHTTP,
+			Endpoint: "http://example.com",
+			Labels:   map[string]string{"team": "db"},
+			Interval: health.Duration(time.Minute),
+			Expect:   &health.Expectation{Codes: "200-299"},
+		}
+		mustNoError(t, repo.Create(check))
+		mustError(t, repo.Create(check))
+
+		got, err := repo.Read(check.ID)
+		mustNoError(t, err)
+		equal(t, check.Labels, got.Labels, "unexpected labels")
+		equal(t, *check.Expect, *got.Expect, "unexpected expectation")
+		equal(t, check.Interval, got.Interval, "unexpected interval")
+
+		check.State = health.StateDown
+		check.Code = 503
+		check.Expect = nil
+		mustNoError(t, repo.Update(check))
+		got, err = repo.Read(check.ID)
+		mustNoError(t, err)
+		equal(t, check, got, "check not updated")
+
+		mustError(t, repo.Update(health.Check{ID: "missing"}))
+
+		mustNoError(t, repo.Delete(check.ID))
+		mustNoError(t, repo.Delete(check.ID))
+		_, err = repo.Read(check.ID)
+		mustError(t, err)
+	})
+
+	t.Run("secrets are not persisted", func(t *testing.T) {
+		repo, _, done := newRepo(t, 0)
+		defer done()
+
+		tests := []struct {
+			name      string
+			request   *health.Request
+			shouldErr bool
+		}{
+			{name: "password", request: &health.Request{Auth: &health.Auth{Type: health.AuthBasic, Username: "user", Password: "pass"}}, shouldErr: true},
+			{name: "token", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, Token: "token"}}, shouldErr: true},
+			{name: "sensitive header", request: &health.Request{Headers: map[string]string{"X-Api-Key": "key"}}, shouldErr: true},
+			{name: "custom auth header", request: &health.Request{Headers: map[string]string{"X-Auth": "s3cr3t"}}, shouldErr: true},
+			{name: "secret header", request: &health.Request{SecretHeaders: map[string]string{"X-Auth": "env:HEALTH_SECRET_AUTH"}}},
+			{name: "secret ref", request: &health.Request{Auth: &health.Auth{Type: health.AuthBearer, SecretRef: "env:HEALTH_SECRET_TOKEN"}}},
+			{name: "headers without secrets", request: &health.Request{Headers: map[string]string{"Accept": "application/json"}}},
+		}
+
+		for i, tt := range tests {
+			fn := func(t *testing.T) {
+				check := health.Check{ID: "id-" + strconv.Itoa(i), Endpoint: "http://example.com", Request: tt.request}
+
+				err := repo.Create(check)
+				if tt.shouldErr {
+					mustError(t, err)
+
+					mustNoError(t, repo.Create(health.Check{ID: check.ID, Endpoint: check.Endpoint}))
+					mustError(t, repo.Update(check))
+					return
+				}
+				mustNoError(t, err)
+			}
+
+			t.Run(tt.name, fn)
+		}
+
+		svc := health.NewSVC(repo)
+		_, err := svc.Create(health.Check{
+			Endpoint: "http://example.com/svc",
+			Request:  &health.Request{Auth: &health.Auth{Type: health.AuthBearer, Token: "token"}},
+		})
+		mustError(t, err)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		repo, _, done := newRepo(t, 0)
+		defer done()
+
+		checks := newChecks(20)
+		for _, c := range checks {
+			mustNoError(t, repo.Create(c))
+		}
+
+		tests := []struct {
+			name       string
+			page, size int
+			expected   []health.Check
+		}{
+			{name: "first page", page: 1, size: 5, expected: checks[:5]},
+			{name: "middle page", page: 3, size: 5, expected: checks[10:15]},
+			{name: "partial page", page: 2, size: 19, expected: checks[19:]},
+			{name: "page that does not exist", page: 100, size: 10, expected: []health.Check{}},
+			{name: "all checks", size: -1, expected: checks},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				total, got := repo.List(tt.page, tt.size)
+
+				equal(t, len(checks), total, "unexpected total")
+				equal(t, tt.expected, got, "unexpected checks")
+			}
+
+			t.Run(tt.name, fn)
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		newResult := func(id string, offset int) health.Result {
+			return health.Result{
+				CheckID:  id,
+				OK:       offset%2 == 0,
+				Code:     int32(200 + offset),
+				Checked:  base.Add(time.Duration(offset) * time.Minute),
+				Duration: health.Duration(time.Duration(offset) * time.Millisecond),
+			}
+		}
+
+		t.Run("results", func(t *testing.T) {
+			repo, _, done := newRepo(t, 24*time.Hour)
+			defer done()
+
+			var expected []health.Result
+			for i := 0; i < 5; i++ {
+				res := newResult("id", i)
+				expected = append(expected, res)
+				mustNoError(t, repo.AppendResult(res))
+			}
+			tls := &health.TLSInfo{Issuer: "CN=issuer", DaysRemaining: 30}
+			mustNoError(t, repo.AppendResult(health.Result{CheckID: "other-id", Checked: base, TLS: tls}))
+
+			results, err := repo.Results("id", base, base.Add(time.Hour), 0)
+			mustNoError(t, err)
+			mustEqual(t, len(expected), len(results), "unexpected number of results")
+			for i := range expected {
+				equal(t, expected[i].Code, results[i].Code, "unexpected code")
+				equal(t, expected[i].OK, results[i].OK, "unexpected ok")
+				equal(t, expected[i].Duration, results[i].Duration, "unexpected duration")
+				equal(t, true, expected[i].Checked.Equal(results[i].Checked), "unexpected checked")
+			}
+
+			results, err = repo.Results("id", base.Add(time.Minute), base.Add(time.Hour), 2)
+			mustNoError(t, err)
+			mustEqual(t, 2, len(results), "unexpected number of limited results")
+			equal(t, int32(203), results[0].Code, "unexpected first result")
+			equal(t, int32(204), results[1].Code, "limited results not the latest")
+
+			results, err = repo.Results("other-id", base, base, 0)
+			mustNoError(t, err)
+			mustEqual(t, 1, len(results), "unexpected number of results")
+			equal(t, *tls, *results[0].TLS, "unexpected tls")
+		})
+
+		t.Run("events", func(t *testing.T) {
+			repo, _, done := newRepo(t, 24*time.Hour)
+			defer done()
+
+			mustNoError(t, repo.AppendEvent(health.Event{CheckID: "id", Time: base, To: health.StatePending, Reason: "check created"}))
+			mustNoError(t, repo.AppendEvent(health.Event{CheckID: "id", Time: base.Add(time.Minute), From: health.StatePending, To: health.StateUp, Reason: "probe succeeded"}))
+
+			events, err := repo.Events("id", base, base.Add(time.Hour), 0)
+			mustNoError(t, err)
+			mustEqual(t, 2, len(events), "unexpected number of events")
+			equal(t, health.StatePending, events[1].From, "unexpected previous state")
+			equal(t, health.StateUp, events[1].To, "unexpected new state")
+			equal(t, "probe succeeded", events[1].Reason, "unexpected reason")
+			equal(t, true, base.Add(time.Minute).Equal(events[1].Time), "unexpected time")
+
+			events, err = repo.Events("id", base, base.Add(time.Hour), 1)
+			mustNoError(t, err)
+			mustEqual(t, 1, len(events), "unexpected number of limited events")
+			equal(t, health.StateUp, events[0].To, "limited events not the latest")
+
+			mustNoError(t, repo.DeleteHistory("id"))
+			events, err = repo.Events("id", base, base.Add(time.Hour), 0)
+			mustNoError(t, err)
+			equal(t, 0, len(events), "history not deleted")
+		})
+
+		t.Run("expired history is pruned", func(t *testing.T) {
+			repo, _, done := newRepo(t, 10*time.Minute)
+			defer done()
+
+			mustNoError(t, repo.AppendEvent(health.Event{CheckID: "id", Time: base, To: health.StatePending}))
+			for i := 0; i < 30; i++ {
+				mustNoError(t, repo.AppendResult(newResult("id", i)))
+			}
+
+			results, err := repo.Results("id", base.Add(-time.Hour), base.Add(time.Hour), 0)
+			mustNoError(t, err)
+			if len(results) > 12 {
+				t.Fatalf("expired results not pruned: got=%d results", len(results))
+			}
+			equal(t, int32(229), results[len(results)-1].Code, "latest result not retained")
+
+			events, err := repo.Events("id", base.Add(-time.Hour), base.Add(time.Hour), 0)
+			mustNoError(t, err)
+			equal(t, 0, len(events), "expired events not pruned")
+		})
+	})
+
+	t.Run("migrations are applied once", func(t *testing.T) {
+		repo, path, done := newRepo(t, 0)
+		defer done()
+
+		mustNoError(t, repo.Create(health.Check{ID: "id", Endpoint: "http://example.com"}))
+		mustNoError(t, repo.Close())
+
+		repo, err := health.NewSQLiteRepository(path, 0)
+		mustNoError(t, err)
+		defer repo.Close()
+
+		_, err = repo.Read("id")
+		mustNoError(t, err)
+
+		db, err := sql.Open("sqlite", path)
+		mustNoError(t, err)
+		defer db.Close()
+
+		var migrations int
+		mustNoError(t, db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&migrations))
+		equal(t, 1, migrations, "unexpected migrations")
+
+		t.Run("fails on a newer schema", func(t *testing.T) {
+			_, err := db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (100, 0)`)
+			mustNoError(t, err)
+
+			_, err = health.NewSQLiteRepository(path, 0)
+			mustError(t, err)
+		})
+	})
+
+	t.Run("checks can be queried with SQL", func(t *testing.T) {
+		repo, path, done := newRepo(t, 0)
+		defer done()
+
+		checks := newChecks(3)
+		checks[1].State = health.StateDown
+		for _, c := range checks {
+			mustNoError(t, repo.Create(c))
+		}
+
+		db, err := sql.Open("sqlite", path)
+		mustNoError(t, err)
+		defer db.Close()
+
+		var endpoint string
+		mustNoError(t, db.QueryRow(`SELECT endpoint FROM checks WHERE state = 'down'`).Scan(&endpoint))
+		equal(t, checks[1].Endpoint, endpoint, "unexpected endpoint")
+	})
+}
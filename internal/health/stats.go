@@ -0,0 +1,166 @@
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+type LatencyStats struct {
+	CheckID string    `json:"check_id"`
+	Window  Duration  `json:"window"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Count   uint64    `json:"count"`
+	Min     Duration  `json:"min"`
+	Max     Duration  `json:"max"`
+	Mean    Duration  `json:"mean"`
+	P50     Duration  `json:"p50"`
+	P90     Duration  `json:"p90"`
+	P95     Duration  `json:"p95"`
+	P99     Duration  `json:"p99"`
+}
+
+func newLatencyStats(sk *sketch, from, to time.Time) LatencyStats {
+	return LatencyStats{
+		Window: Duration(to.Sub(from)),
+		From:   from,
+		To:     to,
+		Count:  sk.count,
+		Min:    Duration(sk.min),
+		Max:    Duration(sk.max),
+		Mean:   Duration(sk.mean()),
+		P50:    Duration(sk.quantile(0.5)),
+		P90:    Duration(sk.quantile(0.9)),
+		P95:    Duration(sk.quantile(0.95)),
+		P99:    Duration(sk.quantile(0.99)),
+	}
+}
+
+const (
+	minuteSlotRetention = 2 * time.Hour
+	hourSlotRetention   = 30 * 24 * time.Hour
+)
+
+// latencyRecorder keeps sketches of the probe latencies of every check in
+// minute wide slots for recent windows and hour wide slots for longer ones.
+type latencyRecorder struct {
+	mu     sync.Mutex
+	series map[string]*latencySeries
+	seeds  map[string]*latencySeed
+}
+
+// latencySeed guards the seeding of a check, such that its history is loaded
+// once, while the latencies of other checks continue to be recorded.
+type latencySeed struct {
+	mu     sync.Mutex
+	seeded bool
+}
+
+type latencySeries struct {
+	minutes map[int64]*sketch
+	hours   map[int64]*sketch
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{
+		series: make(map[string]*latencySeries),
+		seeds:  make(map[string]*latencySeed),
+	}
+}
+
+// seed records the latencies load provides the first time the check is seen.
+// Callers seeding the check concurrently wait for the seeding to complete,
+// and a failed seeding is retried by the next caller.
+func (l *latencyRecorder) seed(id string, load func() error) error {
+	l.mu.Lock()
+	seed, ok := l.seeds[id]
+	if !ok {
+		seed = &latencySeed{}
+		l.seeds[id] = seed
+	}
+	l.mu.Unlock()
+
+	seed.mu.Lock()
+	defer seed.mu.Unlock()
+
+	if seed.seeded {
+		return nil
+	}
+	if err := load(); err != nil {
+		return err
+	}
+	seed.seeded = true
+	return nil
+}
+
+func (l *latencyRecorder) record(id string, checked time.Time, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	series := l.init(id)
+
+	minute, hour := checked.Unix()/60, checked.Unix()/3600
+	if series.minutes[minute] == nil {
+		series.minutes[minute] = newSketch()
+		prune(series.minutes, minute-int64(minuteSlotRetention/time.Minute))
+	}
+	if series.hours[hour] == nil {
+		series.hours[hour] = newSketch()
+		prune(series.hours, hour-int64(hourSlotRetention/time.Hour))
+	}
+	series.minutes[minute].add(d)
+	series.hours[hour].add(d)
+}
+
+func (l *latencyRecorder) init(id string) *latencySeries {
+	series, ok := l.series[id]
+	if !ok {
+		series = &latencySeries{
+			minutes: make(map[int64]*sketch),
+			hours:   make(map[int64]*sketch),
+		}
+		l.series[id] = series
+	}
+	return series
+}
+
+// sketch merges the slots that overlap with [from, to] into a single sketch.
+func (l *latencyRecorder) sketch(id string, from, to time.Time) *sketch {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := newSketch()
+	series, ok := l.series[id]
+	if !ok {
+		return out
+	}
+
+	slots, width := series.hours, int64(3600)
+	if to.Sub(from) <= minuteSlotRetention {
+		slots, width = series.minutes, 60
+	}
+
+	start, end := from.Unix()/width, to.Unix()/width
+	for slot, sk := range slots {
+		if slot >= start && slot <= end {
+			out.merge(sk)
+		}
+	}
+	return out
+}
+
+func (l *latencyRecorder) delete(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.series, id)
+	delete(l.seeds, id)
+}
+
+func prune(slots map[int64]*sketch, before int64) {
+	for slot := range slots {
+		if slot < before {
+			delete(slots, slot)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+)
+
+var errInvalidTCPEndpoint = errors.New("tcp and grpc endpoints must be of the form host:port")
+
+type tcpProber struct {
+	dialer net.Dialer
+}
+
+var _ Prober = (*tcpProber)(nil)
+
+// NewTCPProber provides a prober that connects to the host:port of the check,
+// measuring how long the connection takes to be established.
+func NewTCPProber() Prober {
+	return new(tcpProber)
+}
+
+func (p *tcpProber) Probe(ctx context.Context, c Check) Result {
+	res := Result{
+		CheckID: c.ID,
+		Checked: time.Now(),
+	}
+
+	conn, err := p.dialer.DialContext(ctx, "tcp", c.Endpoint)
+	res.Duration = Duration(time.Since(res.Checked))
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	conn.Close()
+
+	res.OK = true
+	return res
+}
+
+func validateHostPort(endpoint string) error {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil || host == "" {
+		return errInvalidTCPEndpoint
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return errInvalidTCPEndpoint
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package health_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestTCPProber(t *testing.T) {
+	t.Run("listening port is up", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		mustNoError(t, err)
+		defer l.Close()
+
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+
+		res := health.NewTCPProber().Probe(context.Background(), health.Check{ID: "id", Type: health.TypeTCP, Endpoint: l.Addr().String()})
+
+		equal(t, "id", res.CheckID, "unexpected check id")
+		equal(t, true, res.OK, "unexpected failure: "+res.Error)
+		if res.Duration <= 0 {
+			t.Errorf("expected the connect time to be measured: got=%s", time.Duration(res.Duration))
+		}
+	})
+
+	t.Run("closed port is down", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		mustNoError(t, err)
+		addr := l.Addr().String()
+		l.Close()
+
+		res := health.NewTCPProber().Probe(context.Background(), health.Check{ID: "id", Type: health.TypeTCP, Endpoint: addr})
+
+		equal(t, false, res.OK, "unexpected ok")
+		if res.Error == "" {
+			t.Error("expected an error to be recorded")
+		}
+	})
+}
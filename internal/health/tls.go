@@ -0,0 +1,52 @@
+package health
+
+import (
+	"crypto/tls"
+	"errors"
+	"time"
+)
+
+type TLSInfo struct {
+	Expiry        time.Time `json:"expiry"`
+	Issuer        string    `json:"issuer"`
+	Subject       string    `json:"subject"`
+	SANs          []string  `json:"sans"`
+	DaysRemaining int       `json:"days_remaining"`
+	Chain         []TLSCert `json:"chain"`
+}
+
+type TLSCert struct {
+	Subject string    `json:"subject"`
+	Issuer  string    `json:"issuer"`
+	Expiry  time.Time `json:"expiry"`
+}
+
+const maxCertWarningDays = 365
+
+var errInvalidCertWarningDays = errors.New("cert_warning_days must be between 0 and 365")
+
+func newTLSInfo(state *tls.ConnectionState, now time.Time) *TLSInfo {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	leaf := state.PeerCertificates[0]
+	info := &TLSInfo{
+		Expiry:        leaf.NotAfter,
+		Issuer:        leaf.Issuer.String(),
+		Subject:       leaf.Subject.String(),
+		SANs:          append([]string{}, leaf.DNSNames...),
+		DaysRemaining: int(leaf.NotAfter.Sub(now).Hours() / 24),
+	}
+	for _, ip := range leaf.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	for _, cert := range state.PeerCertificates {
+		info.Chain = append(info.Chain, TLSCert{
+			Subject: cert.Subject.String(),
+			Issuer:  cert.Issuer.String(),
+			Expiry:  cert.NotAfter,
+		})
+	}
+	return info
+}
@@ -0,0 +1,83 @@
+package health
+
+import (
+	"time"
+)
+
+type Uptime struct {
+	CheckID string    `json:"check_id"`
+	Window  Duration  `json:"window"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	// Observed is the part of the window the availability is computed
+	// over, from the first probe of the check within the window. It falls
+	// short of the window for a check created within the window, or with
+	// a history that does not retain the results of the whole window.
+	Observed Duration `json:"observed"`
+	Probes   int      `json:"probes"`
+	// Availability is the percentage of the observed time the check was
+	// up. It is nil when the check has not been probed within the window.
+	Availability *float64 `json:"availability"`
+	Downtime     Duration `json:"downtime"`
+	Incidents    int      `json:"incidents"`
+	// MTTR is the mean time to recovery of the incidents that were
+	// resolved within the window.
+	MTTR Duration `json:"mttr"`
+}
+
+// computeUptime derives the uptime from the time ordered results of a check.
+// The outcome of a probe is assumed to hold until the next probe, with the
+// outcome of the last probe holding until the end of the window.
+func computeUptime(results []Result, from, to time.Time) Uptime {
+	u := Uptime{
+		Window: Duration(to.Sub(from)),
+		From:   from,
+		To:     to,
+		Probes: len(results),
+	}
+	if len(results) == 0 {
+		return u
+	}
+
+	var (
+		downtime, recovery time.Duration
+		resolved           int
+		downSince          time.Time
+		down               bool
+	)
+	for i, res := range results {
+		end := to
+		if i+1 < len(results) {
+			end = results[i+1].Checked
+		}
+
+		switch {
+		case !res.OK && !down:
+			down = true
+			downSince = res.Checked
+			u.Incidents++
+		case res.OK && down:
+			down = false
+			resolved++
+			recovery += res.Checked.Sub(downSince)
+		}
+
+		if !res.OK {
+			downtime += end.Sub(res.Checked)
+		}
+	}
+
+	observed := to.Sub(results[0].Checked)
+	availability := 100.0
+	if observed > 0 {
+		availability = 100 * float64(observed-downtime) / float64(observed)
+	}
+
+	u.Observed = Duration(observed)
+	u.Availability = &availability
+	u.Downtime = Duration(downtime)
+	if resolved > 0 {
+		u.MTTR = Duration(recovery / time.Duration(resolved))
+	}
+	return u
+}
@@ -0,0 +1,136 @@
+package health
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+)
+
+type walOp uint8
+
+const (
+	walCreate walOp = iota + 1
+	walUpdate
+	walDelete
+)
+
+// walRecord is a mutation of the checks appended to the write-ahead log, a
+// delete only carries the id of the check.
+type walRecord struct {
+	Op    walOp
+	Check Check
+}
+
+// every record is framed by the length and crc32 of its gob encoding, a
+// record is encoded on its own as the log outlives the encoder.
+const walHeaderSize = 8
+
+func encodeWALRecord(rec walRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, walHeaderSize))
+	if err := gob.NewEncoder(&buf).Encode(rec); err != nil {
+		return nil, err
+	}
+
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b[0:4], uint32(len(b)-walHeaderSize))
+	binary.BigEndian.PutUint32(b[4:8], crc32.ChecksumIEEE(b[walHeaderSize:]))
+	return b, nil
+}
+
+// walTail describes what follows the last intact record of the log read.
+type walTail struct {
+	// size is the number of bytes following the record.
+	size int64
+	// records is the number of intact records framed after the first
+	// incomplete or corrupt one.
+	records int
+}
+
+// readWAL reads the records of the log up to the first record that is
+// incomplete or corrupt, providing the size of the log up to that record. A
+// process that died mid-append leaves such a record at the end of the log,
+// the tail describes any intact records a corrupt record hides beyond it.
+func readWAL(f *os.File) ([]walRecord, int64, walTail, error) {
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, 0, walTail{}, err
+	}
+
+	var (
+		records []walRecord
+		off     int
+	)
+	for len(b)-off >= walHeaderSize {
+		payload, ok := walPayload(b[off:])
+		if !ok {
+			break
+		}
+
+		var rec walRecord
+		if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+			break
+		}
+		records = append(records, rec)
+		off += walHeaderSize + len(payload)
+	}
+
+	tail := walTail{size: int64(len(b) - off)}
+	// the records following are found by the length of the record, which
+	// holds unless the corruption is in the header
+	for next := off; len(b)-next >= walHeaderSize; {
+		n := int(binary.BigEndian.Uint32(b[next : next+4]))
+		if n > len(b)-next-walHeaderSize {
+			break
+		}
+		if _, ok := walPayload(b[next:]); ok && next != off {
+			tail.records++
+		}
+		next += walHeaderSize + n
+	}
+	return records, int64(off), tail, nil
+}
+
+// walPayload provides the payload of the record b starts with, when the record
+// is complete and intact.
+func walPayload(b []byte) ([]byte, bool) {
+	if len(b) < walHeaderSize {
+		return nil, false
+	}
+	n := int(binary.BigEndian.Uint32(b[0:4]))
+	sum := binary.BigEndian.Uint32(b[4:8])
+	if n > len(b)-walHeaderSize {
+		return nil, false
+	}
+
+	payload := b[walHeaderSize : walHeaderSize+n]
+	if crc32.ChecksumIEEE(payload) != sum {
+		return nil, false
+	}
+	return payload, true
+}
+
+// replay applies the records to the checks of the snapshot. The records are
+// applied as upserts and deletes of what may not exist, as the log may be
+// replayed over a snapshot it was already compacted into.
+func replay(snapshot []Check, records []walRecord) *memRepository {
+	r := newMemRepository()
+	for _, c := range snapshot {
+		r.Create(c)
+	}
+
+	for _, rec := range records {
+		switch rec.Op {
+		case walCreate, walUpdate:
+			if err := r.Update(rec.Check); err == errCheckNotFound {
+				r.Create(rec.Check)
+			}
+		case walDelete:
+			r.Delete(rec.Check.ID)
+		}
+	}
+	return r
+}
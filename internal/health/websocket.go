@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketQuery configures the message exchanged with the endpoint of a
+// websocket check once the handshake completes. A check without a message
+// only performs the handshake.
+type WebSocketQuery struct {
+	Message string `json:"message,omitempty"`
+	// Reply must be contained in the first message received in response to
+	// the message.
+	Reply string `json:"reply,omitempty"`
+}
+
+const AssertionReply = "reply"
+
+var (
+	errInvalidWebSocketEndpoint = errors.New("websocket endpoint must be a valid ws:// or wss:// URL")
+	errInvalidWebSocketRequest  = errors.New("request of a websocket check only sends its headers and auth with the handshake, it must not have a method other than GET or a body")
+	errInvalidWebSocketReply    = errors.New("websocket reply is asserted on the reply to the message, it requires a message")
+)
+
+func validateWebSocket(c Check) error {
+	if r := c.Request; r != nil && (r.method() != http.MethodGet || r.Body != "") {
+		return errInvalidWebSocketRequest
+	}
+	if q := c.WebSocket; q != nil && q.Reply != "" && q.Message == "" {
+		return errInvalidWebSocketReply
+	}
+	return nil
+}
+
+type webSocketProber struct {
+	dialer  *websocket.Dialer
+	secrets *Secrets
+}
+
+var _ Prober = (*webSocketProber)(nil)
+
+// NewWebSocketProber provides a prober that performs the upgrade handshake
+// with the endpoint of the check, sending its message and asserting on the
+// reply when one is configured.
+func NewWebSocketProber(secrets *Secrets) Prober {
+	return &webSocketProber{
+		dialer:  &websocket.Dialer{Proxy: http.ProxyFromEnvironment},
+		secrets: secrets,
+	}
+}
+
+func (p *webSocketProber) Probe(ctx context.Context, c Check) Result {
+	res := Result{
+		CheckID: c.ID,
+		Checked: time.Now(),
+	}
+
+	// the headers and auth of the request are sent with the handshake
+	req, err := http.NewRequest(http.MethodGet, c.Endpoint, nil)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if err := c.Request.apply(req, p.secrets); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	// the dialer takes the host of the handshake from the Host header
+	if req.Host != "" {
+		req.Header.Set("Host", req.Host)
+	}
+
+	conn, resp, err := p.dialer.DialContext(ctx, c.Endpoint, req.Header)
+	if resp != nil {
+		res.Code = int32(resp.StatusCode)
+		res.TLS = newTLSInfo(resp.TLS, time.Now())
+	}
+	if err != nil {
+		res.Duration = Duration(time.Since(res.Checked))
+		res.Error = err.Error()
+		return res
+	}
+	defer conn.Close()
+
+	if q := c.WebSocket; q != nil && q.Message != "" {
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetWriteDeadline(deadline)
+			conn.SetReadDeadline(deadline)
+		}
+
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(q.Message)); err != nil {
+			res.Duration = Duration(time.Since(res.Checked))
+			res.Error = err.Error()
+			return res
+		}
+		_, reply, err := conn.ReadMessage()
+		res.Duration = Duration(time.Since(res.Checked))
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		if !strings.Contains(string(reply), q.Reply) {
+			res.Assertion = AssertionReply
+			res.Failure = fmt.Sprintf("reply %q does not contain %q", truncate(string(reply), 256), q.Reply)
+			return res
+		}
+	}
+	res.Duration = Duration(time.Since(res.Checked))
+
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	res.OK = true
+	return res
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
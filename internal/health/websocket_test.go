@@ -0,0 +1,96 @@
+package health_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/jsteenb2/health/internal/health"
+)
+
+func TestWebSocketProber(t *testing.T) {
+	var upgrader websocket.Upgrader
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.URL.Path == "/secured" && r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/virtual" && r.Host != "gateway.internal" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if r.URL.Path == "/silent" {
+				continue
+			}
+			conn.WriteMessage(typ, append([]byte("echo: "), msg...))
+		}
+	}))
+	defer svr.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(svr.URL, "http")
+
+	tests := []struct {
+		name              string
+		path              string
+		query             *health.WebSocketQuery
+		request           *health.Request
+		expectedOK        bool
+		expectedAssertion string
+	}{
+		{name: "handshake", path: "/", expectedOK: true},
+		{name: "echo reply", path: "/", query: &health.WebSocketQuery{Message: "ping", Reply: "echo: ping"}, expectedOK: true},
+		{name: "message without reply assertion", path: "/", query: &health.WebSocketQuery{Message: "ping"}, expectedOK: true},
+		{name: "unexpected reply", path: "/", query: &health.WebSocketQuery{Message: "ping", Reply: "pong"}, expectedAssertion: health.AssertionReply},
+		{name: "handshake headers", path: "/secured", request: &health.Request{Headers: map[string]string{"Authorization": "Bearer token"}}, expectedOK: true},
+		{name: "handshake rejected", path: "/secured"},
+		{name: "handshake host", path: "/virtual", request: &health.Request{Headers: map[string]string{"Host": "gateway.internal"}}, expectedOK: true},
+		{name: "handshake of another host", path: "/virtual"},
+		{name: "websockets broken behind healthy http", path: "/broken"},
+		{name: "no reply within the timeout", path: "/silent", query: &health.WebSocketQuery{Message: "ping"}},
+	}
+
+	for _, tt := range tests {
+		fn := func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
+			res := health.NewWebSocketProber(nil).Probe(ctx, health.Check{
+				ID:        "id",
+				Type:      health.TypeWebSocket,
+				Endpoint:  wsURL + tt.path,
+				Request:   tt.request,
+				WebSocket: tt.query,
+			})
+
+			equal(t, "id", res.CheckID, "unexpected check id")
+			equal(t, tt.expectedOK, res.OK, "unexpected ok: "+res.Error+res.Failure)
+			equal(t, tt.expectedAssertion, res.Assertion, "unexpected failed assertion")
+			if !tt.expectedOK && tt.expectedAssertion == "" && res.Error == "" {
+				t.Error("expected an error to be recorded")
+			}
+		}
+
+		t.Run(tt.name, fn)
+	}
+}
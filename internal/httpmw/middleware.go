@@ -3,6 +3,10 @@ package httpmw
 import (
 	"log"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type Middleware func(http.Handler) http.Handler
@@ -31,3 +35,43 @@ func ContentType(cType string) func(http.Handler) http.Handler {
 		return http.HandlerFunc(fn)
 	}
 }
+
+func Metrics(reg prometheus.Registerer) func(http.Handler) http.Handler {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests served.",
+	}, []string{"method", "code"})
+	durations := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of the HTTP requests served.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+	reg.MustRegister(requests, durations)
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+			defer func() {
+				requests.WithLabelValues(r.Method, strconv.Itoa(rec.code)).Inc()
+				durations.WithLabelValues(r.Method).Observe(time.Since(start).Seconds())
+			}()
+			next.ServeHTTP(rec, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.code = code
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
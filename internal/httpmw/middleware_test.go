@@ -0,0 +1,99 @@
+package httpmw_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/jsteenb2/health/internal/httpmw"
+)
+
+func TestMetrics(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		handler      http.HandlerFunc
+		expectedCode string
+	}{
+		{
+			name:         "implicit status",
+			method:       http.MethodGet,
+			handler:      func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) },
+			expectedCode: "200",
+		},
+		{
+			name:   "explicit status",
+			method: http.MethodPost,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			},
+			expectedCode: "418",
+		},
+		{
+			name:   "first status wins",
+			method: http.MethodDelete,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			expectedCode: "404",
+		},
+	}
+
+	for _, tt := range tests {
+		fn := func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			svr := httptest.NewServer(httpmw.Metrics(reg)(tt.handler))
+			defer svr.Close()
+
+			for i := 0; i < 2; i++ {
+				req, err := http.NewRequest(tt.method, svr.URL, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := svr.Client().Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+			}
+
+			expected := `
+# HELP http_requests_total Total number of HTTP requests served.
+# TYPE http_requests_total counter
+http_requests_total{code="` + tt.expectedCode + `",method="` + tt.method + `"} 2
+`
+			err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "http_requests_total")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			count, err := testutil.GatherAndCount(reg, "http_request_duration_seconds")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 1 {
+				t.Fatalf("unexpected number of duration series: got=%d", count)
+			}
+			mfs, err := reg.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, mf := range mfs {
+				if mf.GetName() != "http_request_duration_seconds" {
+					continue
+				}
+				h := mf.GetMetric()[0].GetHistogram()
+				if got := h.GetSampleCount(); got != 2 {
+					t.Fatalf("unexpected duration sample count: got=%d", got)
+				}
+			}
+		}
+
+		t.Run(tt.name, fn)
+	}
+}